@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// loadAzureKeyVault returns the enabled secrets of the Azure Key Vault vault
+// (a name or a URL) whose name starts with --azure-keyvault-prefix, keyed by
+// their name without the prefix. Credentials are looked up by the Azure SDK's
+// default chain (environment, workload and managed identity, Azure CLI...).
+func loadAzureKeyVault(vault string) (map[string]interface{}, error) {
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("azure key vault %s: %w", vault, err)
+	}
+	vaultURL := vault
+	if !isURL(vaultURL) {
+		vaultURL = "https://" + vault + ".vault.azure.net"
+	}
+	client, err := azsecrets.NewClient(vaultURL, credential, &azsecrets.ClientOptions{
+		ClientOptions: azcore.ClientOptions{Transport: &http.Client{Timeout: httpTimeout}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("azure key vault %s: %w", vault, err)
+	}
+
+	ctx := context.Background()
+	secrets := make(map[string]interface{})
+	pager := client.NewListSecretPropertiesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("azure key vault %s: listing secrets: %w", vault, err)
+		}
+		for _, s := range page.Value {
+			if s.ID == nil || s.Attributes == nil || s.Attributes.Enabled == nil || !*s.Attributes.Enabled {
+				continue
+			}
+			name := s.ID.Name()
+			if !strings.HasPrefix(name, azureKeyVaultPrefix) {
+				continue
+			}
+			secret, err := client.GetSecret(ctx, name, s.ID.Version(), nil)
+			if err != nil {
+				return nil, fmt.Errorf("azure key vault %s: reading secret %s: %w", vault, name, err)
+			}
+			value := ""
+			if secret.Value != nil {
+				value = *secret.Value
+			}
+			secrets[strings.TrimPrefix(name, azureKeyVaultPrefix)] = value
+		}
+	}
+	return secrets, nil
+}
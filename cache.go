@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// isRemote reports whether the data source is fetched over the network, and
+// can therefore be cached with --cache-dir.
+func (s dataSource) isRemote() bool {
+	switch s.format {
+	case "azure-keyvault", "redis-hash":
+		return true
+	}
+	return !s.exec && isURL(s.path)
+}
+
+// cacheKey identifies the contents of a remote data source in the cache. It
+// includes a hash of the --http-header values, so data fetched with different
+// credentials (e.g. Authorization headers) is cached separately.
+func (s dataSource) cacheKey() string {
+	headers := append([]string(nil), httpHeaders...)
+	sort.Strings(headers)
+	sum := sha256.Sum256([]byte(strings.Join(headers, "\n")))
+	return strings.Join([]string{s.format, s.path, azureKeyVaultPrefix, redisURL, hex.EncodeToString(sum[:])}, "\x00")
+}
+
+// fetchRemoteData returns the data of the remote data sources that are not
+// fetched as a file (Azure Key Vault and Redis), encoded as JSON so it can be
+// cached.
+func fetchRemoteData(src dataSource) ([]byte, error) {
+	var data map[string]interface{}
+	var err error
+	switch src.format {
+	case "azure-keyvault":
+		data, err = loadAzureKeyVault(src.path)
+	case "redis-hash":
+		data, err = loadRedisHash(src.path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+// cached returns the contents cached under key in --cache-dir if they are
+// more recent than --cache-ttl. Otherwise, it calls fetch and caches its
+// result. Cache entries are only readable by the current user, as they may
+// contain secrets.
+func cached(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	if cacheDir == "" {
+		return fetch()
+	}
+	sum := sha256.Sum256([]byte(key))
+	path := filepath.Join(cacheDir, hex.EncodeToString(sum[:]))
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < cacheTTL {
+		if b, err := ioutil.ReadFile(filepath.Clean(path)); err == nil {
+			return b, nil
+		}
+	}
+	b, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	// Failing to cache the contents does not prevent rendering
+	if err := os.MkdirAll(cacheDir, 0700); err == nil {
+		if f, err := ioutil.TempFile(cacheDir, ".tmp-"); err == nil {
+			_, werr := f.Write(b)
+			if cerr := f.Close(); werr != nil || cerr != nil || os.Rename(f.Name(), path) != nil {
+				_ = os.Remove(f.Name())
+			}
+		}
+	}
+	return b, nil
+}
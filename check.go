@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// checkKeys compares the data paths referenced by the input template(s) with
+// the data. It returns a report of the data keys that are never referenced and
+// of the referenced keys that are missing from the data, along with the exit
+// code configured for the issues found.
+func checkKeys(data interface{}) ([]byte, int, error) {
+	keys := make(map[string]bool)
+	err := forEachInput(func(name, text string) error {
+		return collectKeys(keys, name, text)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	refs := make([][]pathElem, 0, len(keys))
+	missing := []string{}
+	for k := range keys {
+		elems, err := parsePath(k)
+		if err != nil {
+			return nil, 0, err
+		}
+		refs = append(refs, elems)
+		if !pathExists(data, elems) {
+			missing = append(missing, k)
+		}
+	}
+
+	leaves := make(map[string][]pathElem)
+	collectLeaves(data, nil, leaves)
+	unused := []string{}
+	for leaf, elems := range leaves {
+		used := false
+		for _, r := range refs {
+			if matchPrefix(r, elems) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, leaf)
+		}
+	}
+	sort.Strings(unused)
+	sort.Strings(missing)
+
+	code := 0
+	if len(unused) > 0 {
+		code = unusedExitCode
+	}
+	if len(missing) > 0 && missingExitCode != 0 {
+		code = missingExitCode
+	}
+	if keysFormat == "json" {
+		b, err := json.MarshalIndent(map[string][]string{"unused": unused, "missing": missing}, "", "  ")
+		if err != nil {
+			return nil, 0, err
+		}
+		return append(b, '\n'), code, nil
+	}
+	var sb strings.Builder
+	for _, k := range unused {
+		sb.WriteString("unused  " + k + "\n")
+	}
+	for _, k := range missing {
+		sb.WriteString("missing " + k + "\n")
+	}
+	return []byte(sb.String()), code, nil
+}
+
+// collectLeaves adds the path of every scalar (or empty container) in data to
+// leaves. List indices are replaced with wildcards, so all elements of a list
+// share the same paths.
+func collectLeaves(data interface{}, path []pathElem, leaves map[string][]pathElem) {
+	switch d := data.(type) {
+	case map[string]interface{}:
+		if len(d) > 0 {
+			for k, v := range d {
+				collectLeaves(v, append(path[:len(path):len(path)], pathElem{key: k}), leaves)
+			}
+			return
+		}
+	case []interface{}:
+		if len(d) > 0 {
+			for _, v := range d {
+				collectLeaves(v, append(path[:len(path):len(path)], pathElem{wildcard: true}), leaves)
+			}
+			return
+		}
+	}
+	if len(path) == 0 {
+		return
+	}
+	var sb strings.Builder
+	for _, e := range path {
+		sb.WriteString(e.String())
+	}
+	leaves[sb.String()] = path
+}
+
+// matchPrefix reports whether ref refers to path or to one of its parents.
+func matchPrefix(ref, path []pathElem) bool {
+	if len(ref) > len(path) {
+		return false
+	}
+	for i, r := range ref {
+		p := path[i]
+		switch {
+		case r.wildcard, p.wildcard && r.isIndex:
+		case r.isIndex != p.isIndex || p.wildcard:
+			return false
+		case r.isIndex && r.index != p.index, !r.isIndex && r.key != p.key:
+			return false
+		}
+	}
+	return true
+}
+
+// pathExists reports whether path exists in data. A wildcard matches if any
+// element matches, or if the list or map is empty (and so can't be checked).
+func pathExists(data interface{}, path []pathElem) bool {
+	if len(path) == 0 {
+		return true
+	}
+	e, rest := path[0], path[1:]
+	switch d := data.(type) {
+	case map[string]interface{}:
+		if e.wildcard {
+			for _, v := range d {
+				if pathExists(v, rest) {
+					return true
+				}
+			}
+			return len(d) == 0
+		}
+		v, ok := d[e.key]
+		return ok && !e.isIndex && pathExists(v, rest)
+	case []interface{}:
+		if e.wildcard {
+			for _, v := range d {
+				if pathExists(v, rest) {
+					return true
+				}
+			}
+			return len(d) == 0
+		}
+		return e.isIndex && e.index >= 0 && e.index < len(d) && pathExists(d[e.index], rest)
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// The CIDR functions mirror Terraform's, e.g. {{ cidrsubnet "10.0.0.0/16" 8 2 }}
+// is 10.0.2.0/24 and {{ cidrhost "10.0.2.0/24" 5 }} is 10.0.2.5.
+
+// toInt converts the number v (e.g. decoded from JSON as a float64) to an int.
+func toInt(v interface{}) (int, error) {
+	f, ok := toFloat(v)
+	if !ok || f != float64(int(f)) {
+		return 0, fmt.Errorf("expected an integer, got %v", v)
+	}
+	return int(f), nil
+}
+
+// parseCIDR parses prefix, returning its network address and mask size.
+func parseCIDR(prefix string) (*net.IPNet, int, int, error) {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("invalid CIDR prefix %q", prefix)
+	}
+	if ip4 := network.IP.To4(); ip4 != nil {
+		network.IP = ip4
+	}
+	ones, bits := network.Mask.Size()
+	return network, ones, bits, nil
+}
+
+func ipToInt(ip net.IP) *big.Int {
+	return new(big.Int).SetBytes(ip)
+}
+
+// intToIP converts n to an IP address of the given length in bytes.
+func intToIP(n *big.Int, length int) net.IP {
+	b := n.Bytes()
+	ip := make(net.IP, length)
+	copy(ip[length-len(b):], b)
+	return ip
+}
+
+// cidrhost returns the address of the host number hostnum in prefix, counting
+// from its end if negative.
+func cidrhost(prefix string, hostnum interface{}) (string, error) {
+	network, ones, bits, err := parseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+	n, err := toInt(hostnum)
+	if err != nil {
+		return "", err
+	}
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	num := big.NewInt(int64(n))
+	if n < 0 {
+		num.Add(num, size)
+	}
+	if num.Sign() < 0 || num.Cmp(size) >= 0 {
+		return "", fmt.Errorf("prefix %s has no host number %d", prefix, n)
+	}
+	return intToIP(num.Add(num, ipToInt(network.IP)), len(network.IP)).String(), nil
+}
+
+// cidrsubnet returns the subnet number netnum of prefix extended by newbits
+// bits.
+func cidrsubnet(prefix string, newbits, netnum interface{}) (string, error) {
+	network, ones, bits, err := parseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+	nb, err := toInt(newbits)
+	if err != nil {
+		return "", err
+	}
+	n, err := toInt(netnum)
+	if err != nil {
+		return "", err
+	}
+	if nb < 0 || ones+nb > bits {
+		return "", fmt.Errorf("cannot extend prefix %s by %d bits", prefix, nb)
+	}
+	if n < 0 || big.NewInt(int64(n)).Cmp(new(big.Int).Lsh(big.NewInt(1), uint(nb))) >= 0 {
+		return "", fmt.Errorf("prefix %s extended by %d bits has no subnet number %d", prefix, nb, n)
+	}
+	num := new(big.Int).Lsh(big.NewInt(int64(n)), uint(bits-ones-nb))
+	ip := intToIP(num.Add(num, ipToInt(network.IP)), len(network.IP))
+	return fmt.Sprintf("%s/%d", ip, ones+nb), nil
+}
+
+// cidrsubnets returns consecutive subnets of prefix extended by each of
+// newbits, aligned to their size, like Terraform's cidrsubnets.
+func cidrsubnets(prefix string, newbits ...interface{}) ([]interface{}, error) {
+	network, ones, bits, err := parseCIDR(prefix)
+	if err != nil {
+		return nil, err
+	}
+	start := ipToInt(network.IP)
+	end := new(big.Int).Add(start, new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)))
+	next := new(big.Int).Set(start)
+	subnets := []interface{}{}
+	for _, v := range newbits {
+		nb, err := toInt(v)
+		if err != nil {
+			return nil, err
+		}
+		if nb < 1 || ones+nb > bits {
+			return nil, fmt.Errorf("cannot extend prefix %s by %d bits", prefix, nb)
+		}
+		size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones-nb))
+		// Align the subnet to its size
+		if rem := new(big.Int).Mod(new(big.Int).Sub(next, start), size); rem.Sign() > 0 {
+			next.Add(next, new(big.Int).Sub(size, rem))
+		}
+		if new(big.Int).Add(next, size).Cmp(end) > 0 {
+			return nil, fmt.Errorf("not enough room in prefix %s for a subnet extended by %d bits", prefix, nb)
+		}
+		subnets = append(subnets, fmt.Sprintf("%s/%d", intToIP(next, len(network.IP)), ones+nb))
+		next.Add(next, size)
+	}
+	return subnets, nil
+}
+
+// cidrnetmask returns the netmask of the IPv4 prefix, e.g. 255.255.255.0 for
+// a /24.
+func cidrnetmask(prefix string) (string, error) {
+	network, _, bits, err := parseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+	if bits != 32 {
+		return "", fmt.Errorf("prefix %s is not an IPv4 prefix", prefix)
+	}
+	return net.IP(network.Mask).String(), nil
+}
+
+// cidrContains reports whether the prefix contains the address ip.
+func cidrContains(prefix, ip string) (bool, error) {
+	network, _, _, err := parseCIDR(prefix)
+	if err != nil {
+		return false, err
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false, fmt.Errorf("invalid IP address %q", ip)
+	}
+	return network.Contains(addr), nil
+}
+
+// isIP reports whether s is an IPv4 or IPv6 address.
+func isIP(s string) bool {
+	return net.ParseIP(s) != nil
+}
+
+// isIPv4 reports whether s is an IPv4 address.
+func isIPv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil
+}
+
+// isIPv6 reports whether s is an IPv6 address.
+func isIPv6(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() == nil
+}
+
+// isCIDR reports whether s is a CIDR prefix, e.g. 10.0.0.0/16.
+func isCIDR(s string) bool {
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// toList returns the elements of the list or array v.
+func toList(v interface{}) ([]interface{}, error) {
+	if l, ok := v.([]interface{}); ok {
+		return l, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", v)
+	}
+	l := make([]interface{}, rv.Len())
+	for i := range l {
+		l[i] = rv.Index(i).Interface()
+	}
+	return l, nil
+}
+
+// toMap returns v as a map.
+func toMap(v interface{}) (map[string]interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok && v != nil {
+		return nil, fmt.Errorf("expected a map, got %T", v)
+	}
+	return m, nil
+}
+
+// newMap returns a map with the given keys, which it keeps in that order.
+func newMap(keys []string) map[string]interface{} {
+	m := make(map[string]interface{}, len(keys))
+	if sourceOrder() {
+		setKeyOrder(m, keys)
+	}
+	return m
+}
+
+// dict returns a map from its arguments, alternating keys and values, e.g.
+// {{ dict "name" .name "port" 8080 }}.
+func dict(kv ...interface{}) (map[string]interface{}, error) {
+	if len(kv)%2 != 0 {
+		return nil, fmt.Errorf("dict requires an even number of arguments, got %d", len(kv))
+	}
+	keys := make([]string, 0, len(kv)/2)
+	values := make(map[string]interface{}, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		k, ok := kv[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings, got %T", kv[i])
+		}
+		if _, ok := values[k]; !ok {
+			keys = append(keys, k)
+		}
+		values[k] = kv[i+1]
+	}
+	m := newMap(keys)
+	for k, v := range values {
+		m[k] = v
+	}
+	return m, nil
+}
+
+// list returns a list of its arguments.
+func list(v ...interface{}) []interface{} {
+	return append([]interface{}{}, v...)
+}
+
+// merge returns a new map with the maps deeply merged in order, the values of
+// the later ones taking precedence, like repeated data sources.
+func merge(maps ...interface{}) (map[string]interface{}, error) {
+	result := newMap(nil)
+	for _, v := range maps {
+		m, err := toMap(v)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := mergeData(result, copyMaps(m), true); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// keys returns the keys of the map m, in the order maps are iterated in.
+func keys(m interface{}) ([]interface{}, error) {
+	mm, err := toMap(m)
+	if err != nil {
+		return nil, err
+	}
+	l := []interface{}{}
+	for _, k := range orderedKeys(mm) {
+		l = append(l, k)
+	}
+	return l, nil
+}
+
+// values returns the values of the map m, in the order of its keys.
+func values(m interface{}) ([]interface{}, error) {
+	mm, err := toMap(m)
+	if err != nil {
+		return nil, err
+	}
+	l := []interface{}{}
+	for _, k := range orderedKeys(mm) {
+		l = append(l, mm[k])
+	}
+	return l, nil
+}
+
+// has reports whether the map or list c has the key or element v, e.g.
+// {{ if has "debug" .flags }}.
+func has(v interface{}, c interface{}) (bool, error) {
+	if m, ok := c.(map[string]interface{}); ok {
+		k, ok := v.(string)
+		if !ok {
+			return false, nil
+		}
+		_, found := m[k]
+		return found, nil
+	}
+	if c == nil {
+		return false, nil
+	}
+	l, err := toList(c)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range l {
+		if reflect.DeepEqual(e, v) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pick returns a new map with only the given keys of m.
+func pick(m interface{}, keys ...string) (map[string]interface{}, error) {
+	mm, err := toMap(m)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		wanted[k] = true
+	}
+	return filterMap(mm, func(k string) bool { return wanted[k] }), nil
+}
+
+// omit returns a new map with all the keys of m but the given ones.
+func omit(m interface{}, keys ...string) (map[string]interface{}, error) {
+	mm, err := toMap(m)
+	if err != nil {
+		return nil, err
+	}
+	unwanted := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		unwanted[k] = true
+	}
+	return filterMap(mm, func(k string) bool { return !unwanted[k] }), nil
+}
+
+// filterMap returns a new map with the keys of m for which keep is true, in
+// the same order.
+func filterMap(m map[string]interface{}, keep func(string) bool) map[string]interface{} {
+	var keys []string
+	for _, k := range orderedKeys(m) {
+		if keep(k) {
+			keys = append(keys, k)
+		}
+	}
+	result := newMap(keys)
+	for _, k := range keys {
+		result[k] = m[k]
+	}
+	return result
+}
+
+// sortBy returns the list l of maps sorted by the value of their key, or of
+// its elements themselves if key is empty, e.g. {{ .services | sortBy "name" }}.
+// Numbers are compared by value and anything else as strings.
+func sortBy(key string, l interface{}) ([]interface{}, error) {
+	elems, err := toList(l)
+	if err != nil {
+		return nil, err
+	}
+	sortKeys := make([]interface{}, len(elems))
+	for i, e := range elems {
+		sortKeys[i] = e
+		if key != "" {
+			if sortKeys[i], err = get(e, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	indexes := make([]int, len(elems))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return lessValue(sortKeys[indexes[i]], sortKeys[indexes[j]])
+	})
+	sorted := make([]interface{}, len(elems))
+	for i, n := range indexes {
+		sorted[i] = elems[n]
+	}
+	return sorted, nil
+}
+
+// lessValue reports whether a sorts before b: by value if both are numbers,
+// else as strings. Missing values sort first.
+func lessValue(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	fa, aNum := toFloat(a)
+	fb, bNum := toFloat(b)
+	if aNum && bNum {
+		return fa < fb
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+// toFloat returns the number v as a float64, or false if it isn't a number.
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// uniq returns the list l without its duplicate elements.
+func uniq(l interface{}) ([]interface{}, error) {
+	elems, err := toList(l)
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for _, e := range elems {
+		dup := false
+		for _, r := range result {
+			if reflect.DeepEqual(e, r) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// first returns the first element of the list l, or nil if it's empty.
+func first(l interface{}) (interface{}, error) {
+	elems, err := toList(l)
+	if err != nil || len(elems) == 0 {
+		return nil, err
+	}
+	return elems[0], nil
+}
+
+// last returns the last element of the list l, or nil if it's empty.
+func last(l interface{}) (interface{}, error) {
+	elems, err := toList(l)
+	if err != nil || len(elems) == 0 {
+		return nil, err
+	}
+	return elems[len(elems)-1], nil
+}
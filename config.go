@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is the config file read from the current directory when
+// --config is not given.
+const defaultConfigFile = ".datasubst.yaml"
+
+// loadConfig applies the options of the config file at path (or of
+// .datasubst.yaml, if it exists, when path is empty). The config file is a
+// YAML map from long flag names to values, with lists for repeatable flags,
+// plus an optional command list:
+//
+//	json-data: [base.json, app=app.json]
+//	delimiters: '((:))'
+//	strict: true
+//	exclude: ['**/*.md']
+//	output-dir: out
+//	command: [nginx, -g, daemon off;]
+//
+// Options are applied in the order of the file. Flags given on the command
+// line override the config file, except for data sources: the ones given on
+// the command line are merged over the ones from the config file. As
+// .datasubst.yaml is read from wherever datasubst runs, it can't run commands
+// (command and exec-data), which require an explicit --config.
+func loadConfig(path string) error {
+	explicit := path != ""
+	if !explicit {
+		if _, err := os.Stat(defaultConfigFile); err != nil {
+			return nil
+		}
+		path = defaultConfigFile
+	}
+	b, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	config := doc.Content[0]
+	if config.Kind != yaml.MappingNode {
+		return fmt.Errorf("parsing config file %s: expected a map of options", path)
+	}
+
+	// Flags and their aliases (e.g. -i and --input) share the same value
+	set := make(map[flag.Value]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Value] = true
+	})
+	cliSources := dataSources
+	dataSources = nil
+
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(config.Content); i += 2 {
+		name := config.Content[i].Value
+		if seen[name] {
+			return fmt.Errorf("config file %s: %s is set more than once", path, name)
+		}
+		seen[name] = true
+		var value interface{}
+		if err := config.Content[i+1].Decode(&value); err != nil {
+			return fmt.Errorf("config file %s: invalid value for %s: %w", path, name, err)
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		switch name {
+		case "command", "exec-data":
+			if !explicit {
+				return fmt.Errorf("config file %s: %s can only be set in a config file given with --config", path, name)
+			}
+		}
+		if name == "command" {
+			if len(command) == 0 {
+				for _, v := range values {
+					command = append(command, fmt.Sprint(v))
+				}
+			}
+			continue
+		}
+		switch name {
+		case "config", "help", "version":
+			return fmt.Errorf("config file %s: %s cannot be set in a config file", path, name)
+		}
+		f := flag.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("config file %s: unknown option %q", path, name)
+		}
+		if set[f.Value] && !isDataSourceFlag(f.Value) {
+			continue
+		}
+		for _, v := range values {
+			switch v.(type) {
+			case map[string]interface{}, []interface{}, nil:
+				return fmt.Errorf("config file %s: invalid value for %s", path, name)
+			}
+			if err := flag.Set(name, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("config file %s: invalid value for %s: %w", path, name, err)
+			}
+		}
+	}
+	dataSources = append(dataSources, cliSources...)
+	return nil
+}
+
+// isDataSourceFlag reports whether v is the value of a data source flag.
+func isDataSourceFlag(v flag.Value) bool {
+	switch v.(type) {
+	case dataSourceFlag, valuesFlag, execDataFlag:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cryptoFuncs returns the functions generating secrets (e.g. for basic auth or
+// development TLS setups). They require --allow-random like the random
+// functions, and always use crypto/rand: they fail with --reproducible, as
+// secrets derived from SOURCE_DATE_EPOCH could be recomputed by anyone.
+func cryptoFuncs() template.FuncMap {
+	return template.FuncMap{
+		"bcrypt": func(password string, cost ...interface{}) (string, error) {
+			if err := requireSecureRandom("bcrypt"); err != nil {
+				return "", err
+			}
+			c := bcrypt.DefaultCost
+			if len(cost) > 0 {
+				var err error
+				if c, err = toInt(cost[0]); err != nil {
+					return "", err
+				}
+			}
+			return bcryptHash(password, c)
+		},
+		"htpasswd": func(user, password string) (string, error) {
+			if err := requireSecureRandom("htpasswd"); err != nil {
+				return "", err
+			}
+			if strings.Contains(user, ":") {
+				return "", fmt.Errorf("invalid htpasswd user %q, can't contain ':'", user)
+			}
+			h, err := bcryptHash(password, bcrypt.DefaultCost)
+			if err != nil {
+				return "", err
+			}
+			return user + ":" + h, nil
+		},
+		"genPrivateKey": func(typ string) (string, error) {
+			if err := requireSecureRandom("genPrivateKey"); err != nil {
+				return "", err
+			}
+			key, err := generateKey(typ)
+			if err != nil {
+				return "", err
+			}
+			return encodePrivateKey(key)
+		},
+		"genSelfSignedCert": func(cn string, ips, dnsNames interface{}, days interface{}) (certificate, error) {
+			if err := requireSecureRandom("genSelfSignedCert"); err != nil {
+				return certificate{}, err
+			}
+			return genSelfSignedCert(cn, ips, dnsNames, days)
+		},
+	}
+}
+
+// requireSecureRandom returns an error unless the function name, generating
+// secrets, can use crypto/rand: it requires --allow-random, and can't be used
+// with --reproducible.
+func requireSecureRandom(name string) error {
+	if err := requireRandom(name); err != nil {
+		return err
+	}
+	if reproducible {
+		return fmt.Errorf("%s can't be used with --reproducible, as its secrets would be predictable", name)
+	}
+	return nil
+}
+
+// bcryptHash returns the bcrypt hash of password with the given cost (the base
+// 2 logarithm of the number of rounds).
+func bcryptHash(password string, cost int) (string, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return "", fmt.Errorf("invalid bcrypt cost %d, must be between %d and %d", cost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// generateKey generates a private key of type typ: rsa (4096 bits), ecdsa
+// (P-256) or ed25519.
+func generateKey(typ string) (crypto.Signer, error) {
+	switch typ {
+	case "rsa":
+		return rsa.GenerateKey(cryptorand.Reader, 4096)
+	case "ecdsa":
+		return ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(cryptorand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("invalid key type %q, must be 'rsa', 'ecdsa' or 'ed25519'", typ)
+}
+
+// encodePrivateKey encodes key in PEM: PKCS #1 for RSA keys, SEC 1 for ECDSA
+// keys and PKCS #8 for Ed25519 keys.
+func encodePrivateKey(key crypto.Signer) (string, error) {
+	var block *pem.Block
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	case *ecdsa.PrivateKey:
+		b, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}
+	default:
+		b, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: b}
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+// certificate is a certificate and its private key, in PEM, returned by
+// genSelfSignedCert, e.g. {{ $c := genSelfSignedCert "example.com" nil nil 365 }}
+// {{ $c.Cert }}{{ $c.Key }}.
+type certificate struct {
+	Cert, Key string
+}
+
+// genSelfSignedCert generates a self-signed server certificate for the common
+// name cn and the lists of IP addresses ips and DNS names dnsNames, valid for
+// days days from now, with a 2048 bits RSA key.
+func genSelfSignedCert(cn string, ips, dnsNames interface{}, days interface{}) (certificate, error) {
+	n, err := toInt(days)
+	if err != nil {
+		return certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             renderTime(),
+		NotAfter:              renderTime().Add(time.Duration(n) * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	if ips != nil {
+		l, err := toList(ips)
+		if err != nil {
+			return certificate{}, err
+		}
+		for _, e := range l {
+			ip := net.ParseIP(fmt.Sprint(e))
+			if ip == nil {
+				return certificate{}, fmt.Errorf("invalid IP address %q", e)
+			}
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		}
+	}
+	if dnsNames != nil {
+		l, err := toList(dnsNames)
+		if err != nil {
+			return certificate{}, err
+		}
+		for _, e := range l {
+			tmpl.DNSNames = append(tmpl.DNSNames, fmt.Sprint(e))
+		}
+	}
+	serial, err := cryptorand.Int(cryptorand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return certificate{}, err
+	}
+	tmpl.SerialNumber = serial
+
+	key, err := rsa.GenerateKey(cryptorand.Reader, 2048)
+	if err != nil {
+		return certificate{}, err
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return certificate{}, err
+	}
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return certificate{}, err
+	}
+	return certificate{
+		Cert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Key:  keyPEM,
+	}, nil
+}
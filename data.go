@@ -0,0 +1,856 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// dataSource is a data file and the format it is encoded in. When name is
+// set, the data is mounted under that top-level key. Helm values files are
+// merged using Helm's semantics. For command data sources, path is the
+// command whose output is decoded.
+type dataSource struct {
+	format string
+	path   string
+	name   string
+	values bool
+	exec   bool
+}
+
+// dataSourceFlag is a repeatable flag that appends data sources of a given
+// format to a shared list, so the order of sources on the command line is
+// preserved across formats.
+type dataSourceFlag struct {
+	format  string
+	sources *[]dataSource
+}
+
+func (f dataSourceFlag) String() string {
+	return ""
+}
+
+// Set adds a data source given as PATH or NAME=PATH. NAME may only contain
+// letters, digits, '_' and '-', so paths and URLs containing '=' are still
+// supported (use ./PATH for local files that would be ambiguous).
+func (f dataSourceFlag) Set(v string) error {
+	src := dataSource{format: f.format, path: v}
+	if i := strings.Index(v, "="); i > 0 && isSourceName(v[:i]) {
+		src.name, src.path = v[:i], v[i+1:]
+	}
+	*f.sources = append(*f.sources, src)
+	return nil
+}
+
+// isFile reports whether the data source is read from a local file (or the
+// standard input), rather than fetched over the network or produced by a
+// command.
+func (s dataSource) isFile() bool {
+	return !s.exec && !s.isRemote()
+}
+
+func isSourceName(s string) bool {
+	for i, c := range s {
+		if !(c == '_' || c == '-' && i > 0 || unicode.IsLetter(c) || unicode.IsDigit(c) && i > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// valuesFlag is a repeatable flag adding Helm values files, YAML data sources
+// merged with Helm's semantics, to a shared list of data sources.
+type valuesFlag struct {
+	sources *[]dataSource
+}
+
+func (f valuesFlag) String() string {
+	return ""
+}
+
+func (f valuesFlag) Set(v string) error {
+	*f.sources = append(*f.sources, dataSource{format: "yaml", path: v, values: true})
+	return nil
+}
+
+// execDataFlag is a repeatable flag adding commands whose output, in the
+// format given by --exec-format, is used as a data source.
+type execDataFlag struct {
+	sources *[]dataSource
+}
+
+func (f execDataFlag) String() string {
+	return ""
+}
+
+func (f execDataFlag) Set(v string) error {
+	*f.sources = append(*f.sources, dataSource{path: v, exec: true})
+	return nil
+}
+
+// stringsFlag is a repeatable flag holding a list of values.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// setFlag is a repeatable flag holding path=value overrides.
+type setFlag []string
+
+func (f *setFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *setFlag) Set(v string) error {
+	if i := strings.Index(v, "="); i <= 0 || v[:i] == "." {
+		return fmt.Errorf("invalid format %q, must be 'path.to.key=value'", v)
+	}
+	*f = append(*f, v)
+	return nil
+}
+
+// envDataKey is the key the environment variables are exposed under when
+// --env-data is combined with other data sources.
+const envDataKey = "Env"
+
+// loadData reads the configured data sources, merging them in order, and
+// returns the resulting template data.
+func loadData() (interface{}, error) {
+	var data, env interface{}
+	var err error
+	resetKeyOrders()
+	if envFlag {
+		env, err = parseEnv()
+		if err != nil {
+			return nil, err
+		}
+		if len(dataSources) == 0 {
+			data = env
+		}
+	}
+	parsed, err := parseDataSources(dataSources)
+	if err != nil {
+		return nil, err
+	}
+	for i, src := range dataSources {
+		d := parsed[i]
+		if src.name != "" {
+			d = map[string]interface{}{src.name: d}
+		}
+		if src.values {
+			data = mergeValues(data, d)
+			continue
+		}
+		data, err = mergeData(data, d, mergeStrategy == "deep")
+		if err != nil {
+			return nil, fmt.Errorf("merging %s data %q: %w", src.format, src.path, err)
+		}
+	}
+	if subtree != "" {
+		data, err = lookupPath(data, subtree)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if query != "" {
+		data, err = applyQuery(data, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if envFlag && len(dataSources) > 0 {
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot add environment variables under .%s: data is not a map", envDataKey)
+		}
+		m[envDataKey] = env
+	}
+	if gitRepo != "" {
+		meta, err := loadGitData(gitRepo)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot add git metadata under .%s: data is not a map", gitDataKey)
+		}
+		m[gitDataKey] = meta
+	}
+	if keyMangle {
+		data = mangleKeys(data)
+	}
+	for _, s := range setValues {
+		kv := strings.SplitN(s, "=", 2)
+		data, err = setValue(data, kv[0], kv[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if expandDataFlag {
+		return expandData(data)
+	}
+	return data, nil
+}
+
+// mangleKeys returns v with the keys of its maps that aren't valid identifiers
+// (e.g. containing dashes, dots or spaces) rewritten with --key-mangle, so
+// templates can address them as fields: invalid characters are replaced with
+// '_', and '_' is prepended to keys starting with a digit (e.g. "app-name" is
+// .app_name and "1st place" is ._1st_place). Mangled keys never override
+// existing keys.
+func mangleKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		var mangled []string
+		for k, e := range v {
+			if mk := mangleKey(k); mk != k {
+				mangled = append(mangled, k)
+				continue
+			}
+			m[k] = mangleKeys(e)
+		}
+		sort.Strings(mangled)
+		for _, k := range mangled {
+			mk := mangleKey(k)
+			if _, ok := m[mk]; ok {
+				log.Printf("Warning: key %q is not mangled as %q already exists\n", k, mk)
+				m[k] = mangleKeys(v[k])
+				continue
+			}
+			m[mk] = mangleKeys(v[k])
+		}
+		if keys, ok := recordedKeys(v); ok {
+			order := make([]string, len(keys))
+			for i, k := range keys {
+				order[i] = k
+				if _, kept := m[k]; !kept {
+					order[i] = mangleKey(k)
+				}
+			}
+			setKeyOrder(m, order)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = mangleKeys(e)
+		}
+		return l
+	}
+	return v
+}
+
+// mangleKey returns key as a valid identifier for --key-mangle.
+func mangleKey(key string) string {
+	b := []rune(key)
+	for i, r := range b {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || unicode.IsDigit(b[0]) {
+		b = append([]rune{'_'}, b...)
+	}
+	return string(b)
+}
+
+// isDataFormat reports whether format is a format data sources can be
+// decoded from.
+func isDataFormat(format string) bool {
+	switch format {
+	case "json", "yaml", "toml", "dotenv", "ini", "properties", "hcl", "ndjson", "terraform":
+		return true
+	}
+	return false
+}
+
+// dataSourceFormat returns the format of a --data source: the one given with
+// --data-format or, failing that, the one matching its file extension.
+func dataSourceFormat(path string) (string, error) {
+	if isDataFormat(dataFormat) {
+		return dataFormat, nil
+	} else if dataFormat != "" {
+		return "", fmt.Errorf("invalid data format %q. Must be 'json', 'yaml', 'toml', 'dotenv', 'ini', 'properties', 'hcl', 'ndjson' or 'terraform'", dataFormat)
+	}
+	if u, err := url.Parse(path); err == nil && isURL(path) {
+		path = u.Path
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".json" || ext == ".jsonc":
+		return "json", nil
+	case ext == ".yaml" || ext == ".yml":
+		return "yaml", nil
+	case ext == ".toml":
+		return "toml", nil
+	case ext == ".env":
+		return "dotenv", nil
+	case ext == ".ini":
+		return "ini", nil
+	case ext == ".properties":
+		return "properties", nil
+	case ext == ".hcl" || ext == ".tfvars":
+		return "hcl", nil
+	case ext == ".ndjson" || ext == ".jsonl":
+		return "ndjson", nil
+	}
+	return "", fmt.Errorf("cannot guess the format of %q, please specify --data-format", path)
+}
+
+// parseDataSources parses sources using up to --fetch-workers goroutines, so
+// remote data sources are fetched concurrently. It returns their data in the
+// same order, or the error of the first one (in order) that failed.
+func parseDataSources(sources []dataSource) ([]interface{}, error) {
+	data := make([]interface{}, len(sources))
+	errs := make([]error, len(sources))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < fetchWorkers && w < len(sources); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				data[i], errs[i] = parseDataSource(sources[i])
+			}
+		}()
+	}
+	for i := range sources {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func parseDataSource(src dataSource) (interface{}, error) {
+	if src.format == "azure-keyvault" || src.format == "redis-hash" {
+		b, err := cached(src.cacheKey(), func() ([]byte, error) {
+			return fetchRemote(src.path, func() ([]byte, error) {
+				return fetchRemoteData(src)
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		var data interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	var b []byte
+	var err error
+	switch {
+	case src.exec:
+		b, err = runDataCommand(src.path)
+	case src.isRemote():
+		b, err = cached(src.cacheKey(), func() ([]byte, error) {
+			return fetchRemote(redactURL(src.path), func() ([]byte, error) {
+				return readDataSource(src.path)
+			})
+		})
+	default:
+		b, err = readDataSource(src.path)
+	}
+	if err == nil {
+		b, err = decodeText(b, dataEncoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeData(src.format, b)
+	if err != nil {
+		return nil, err
+	}
+	if sopsFlag || isSOPSEncrypted(data) {
+		if b, err = sopsDecrypt(src, b); err != nil {
+			return nil, err
+		}
+		return decodeData(src.format, b)
+	}
+	return data, nil
+}
+
+// decodeData decodes b, encoded in the given data source format.
+func decodeData(format string, b []byte) (interface{}, error) {
+	var data interface{}
+	var err error
+	switch format {
+	case "yaml":
+		data, err = decodeYAML(b)
+	case "toml":
+		err = toml.Unmarshal(b, &data)
+	case "dotenv":
+		data, err = decodeDotenv(string(b))
+	case "ini":
+		data, err = decodeINI(string(b))
+	case "properties":
+		data, err = decodeProperties(string(b))
+	case "hcl":
+		data, err = decodeHCL(b)
+	case "ndjson":
+		data, err = decodeNDJSON(b)
+	case "terraform":
+		data, err = decodeTerraformOutput(b)
+	default:
+		// JSON with comments and trailing commas (JSONC) is accepted too
+		if err = json.Unmarshal(b, &data); err == nil {
+			recordJSONOrder(b, data)
+		} else if stripped := stripJSONC(b); json.Unmarshal(stripped, &data) == nil {
+			recordJSONOrder(stripped, data)
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decodeNDJSON decodes newline delimited JSON into a list with one element per
+// (non-blank) line.
+func decodeNDJSON(b []byte) ([]interface{}, error) {
+	records := []interface{}{}
+	for i, l := range bytes.Split(b, []byte("\n")) {
+		if len(bytes.TrimSpace(l)) == 0 {
+			continue
+		}
+		var record interface{}
+		if err := json.Unmarshal(l, &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// decodeTerraformOutput decodes the output of 'terraform output -json',
+// unwrapping the {"value", "type", "sensitive"} object of each output so that
+// its value is available directly under its name.
+func decodeTerraformOutput(b []byte) (map[string]interface{}, error) {
+	var outputs map[string]interface{}
+	if err := json.Unmarshal(b, &outputs); err != nil {
+		return nil, err
+	}
+	for name, o := range outputs {
+		m, ok := o.(map[string]interface{})
+		if _, hasValue := m["value"]; !ok || !hasValue {
+			return nil, fmt.Errorf("output %s: expected an object with a value", name)
+		}
+		outputs[name] = m["value"]
+	}
+	return outputs, nil
+}
+
+// yamlDocumentsKey is the key the documents of a multi-document YAML data
+// source are listed under with --yaml-documents list.
+const yamlDocumentsKey = "docs"
+
+// decodeYAML decodes all the documents in b. A single document is returned as
+// is, multiple documents are listed under .docs or merged together, depending
+// on --yaml-documents.
+func decodeYAML(b []byte) (interface{}, error) {
+	var docs []interface{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		var node yaml.Node
+		if err := dec.Decode(&node); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var doc interface{}
+		if err := node.Decode(&doc); err != nil {
+			return nil, err
+		}
+		recordYAMLOrder(&node, doc)
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	switch {
+	case len(docs) == 0:
+		return nil, nil
+	case len(docs) == 1:
+		return docs[0], nil
+	case yamlDocuments == "merge":
+		var data interface{}
+		for i, doc := range docs {
+			var err error
+			if data, err = mergeData(data, doc, mergeStrategy == "deep"); err != nil {
+				return nil, fmt.Errorf("merging YAML document %d: %w", i+1, err)
+			}
+		}
+		return data, nil
+	}
+	return map[string]interface{}{yamlDocumentsKey: docs}, nil
+}
+
+// encodeData encodes data as indented "json" or "yaml".
+func encodeData(format string, data interface{}) ([]byte, error) {
+	var s string
+	var err error
+	if format == "yaml" {
+		s, err = toYAML(normalizeData(data))
+	} else {
+		s, err = toPrettyJSON(normalizeData(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s + "\n"), nil
+}
+
+// encodeValue encodes a single value for the get command: strings are printed
+// as is, other values in the --print-data format (JSON by default).
+func encodeValue(v interface{}) ([]byte, error) {
+	if s, ok := v.(string); ok && printData == "" {
+		return []byte(s + "\n"), nil
+	}
+	format := printData
+	if format == "" {
+		format = "json"
+	}
+	return encodeData(format, v)
+}
+
+// mergeData merges src into dst and returns the result. Keys in src override
+// keys in dst; when deep is true, nested maps present in both are merged
+// recursively instead of replaced. Non-map values are always replaced. A nil
+// src (e.g. an empty or comment-only YAML file) leaves dst unchanged, and
+// merging a map with a non-map value is an error.
+func mergeData(dst, src interface{}, deep bool) (interface{}, error) {
+	if src == nil {
+		return dst, nil
+	}
+	if dst == nil {
+		return src, nil
+	}
+	dstMap, dstIsMap := dst.(map[string]interface{})
+	srcMap, srcIsMap := src.(map[string]interface{})
+	if !dstIsMap && !srcIsMap {
+		return src, nil
+	}
+	if !dstIsMap || !srcIsMap {
+		return nil, fmt.Errorf("can't merge %s into %s", dataKind(src), dataKind(dst))
+	}
+	for _, k := range orderedKeys(srcMap) {
+		v := srcMap[k]
+		if deep {
+			if _, ok := dstMap[k].(map[string]interface{}); ok {
+				if _, ok := v.(map[string]interface{}); ok {
+					v, _ = mergeData(dstMap[k], v, deep)
+				}
+			}
+		}
+		if _, ok := dstMap[k]; !ok {
+			appendKeyOrder(dstMap, k)
+		}
+		dstMap[k] = v
+	}
+	return dstMap, nil
+}
+
+// dataKind describes the kind of the data value v in errors.
+func dataKind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "a map"
+	case []interface{}:
+		return "a list"
+	}
+	return fmt.Sprintf("a %T value", v)
+}
+
+// mergeValues merges the Helm values src into dst and returns the result, like
+// Helm does: maps are merged recursively, other values (including lists) are
+// replaced, null values remove the key and empty values files are ignored.
+func mergeValues(dst, src interface{}) interface{} {
+	if src == nil {
+		return dst
+	}
+	dstMap, ok := dst.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	srcMap, ok := src.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	for _, k := range orderedKeys(srcMap) {
+		v := srcMap[k]
+		if v == nil {
+			delete(dstMap, k)
+			removeKeyOrder(dstMap, k)
+			continue
+		}
+		if _, ok := dstMap[k].(map[string]interface{}); ok {
+			if _, ok := v.(map[string]interface{}); ok {
+				v = mergeValues(dstMap[k], v)
+			}
+		}
+		if _, ok := dstMap[k]; !ok {
+			appendKeyOrder(dstMap, k)
+		}
+		dstMap[k] = v
+	}
+	return dstMap
+}
+
+// setValue sets the value at path (e.g. .my_key.my_subkey or .items[0].name)
+// in data, creating intermediate maps as needed, and returns the resulting
+// data. List indices must refer to existing elements.
+func setValue(data interface{}, path string, value interface{}) (interface{}, error) {
+	elems, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("cannot set %q: empty path", path)
+	}
+	for _, e := range elems {
+		if e.wildcard {
+			return nil, fmt.Errorf("cannot set %q: wildcards are not supported", path)
+		}
+	}
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	cur := data
+	for i, e := range elems {
+		last := i == len(elems)-1
+		var next interface{}
+		if e.isIndex {
+			l, ok := cur.([]interface{})
+			if !ok || e.index < 0 || e.index >= len(l) {
+				return nil, fmt.Errorf("cannot set %q: index %d out of range", path, e.index)
+			}
+			if last {
+				l[e.index] = value
+				break
+			}
+			if !isContainer(l[e.index]) {
+				l[e.index] = make(map[string]interface{})
+			}
+			next = l[e.index]
+		} else {
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("cannot set %q: %s is not a map", path, e)
+			}
+			if _, ok := m[e.key]; !ok {
+				appendKeyOrder(m, e.key)
+			}
+			if last {
+				m[e.key] = value
+				break
+			}
+			if !isContainer(m[e.key]) {
+				m[e.key] = make(map[string]interface{})
+			}
+			next = m[e.key]
+		}
+		cur = next
+	}
+	return data, nil
+}
+
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// defaultEnvDenyPattern matches the names of the environment variables that
+// look sensitive (e.g. GITHUB_TOKEN), left out by --env-data unless they match
+// --env-allow.
+const defaultEnvDenyPattern = `(?i).*(TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIAL|PRIVATE_KEY|API_KEY|ACCESS_KEY).*`
+
+var (
+	defaultEnvDeny = regexp.MustCompile("^(?:" + defaultEnvDenyPattern + ")$")
+	// envAllowPatterns and envDenyPatterns are the compiled --env-allow and
+	// --env-deny patterns.
+	envAllowPatterns, envDenyPatterns []*regexp.Regexp
+)
+
+// compileEnvPatterns compiles the --env-allow or --env-deny patterns, which
+// match whole variable names.
+func compileEnvPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// envExposed reports whether the environment variable name is exposed by
+// --env-data: it must not match --env-deny and, if set, must match
+// --env-allow. Otherwise, sensitive-looking names are left out.
+func envExposed(name string) bool {
+	switch {
+	case matchAnyRegexp(envDenyPatterns, name):
+		return false
+	case len(envAllowPatterns) > 0:
+		return matchAnyRegexp(envAllowPatterns, name)
+	}
+	return !defaultEnvDeny.MatchString(name)
+}
+
+func matchAnyRegexp(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseEnv() (interface{}, error) {
+	data := make(map[string]interface{})
+	env := os.Environ()
+	// Sorting guarantees parents (e.g. DB) are processed before their nested
+	// variables (e.g. DB__HOST) with --env-nested, which then take precedence.
+	sort.Strings(env)
+	for _, v := range env {
+		name, s, ok := splitEnv(v)
+		if !ok || !envExposed(name) {
+			continue
+		}
+		value := interface{}(s)
+		if envParseValues {
+			value = parseEnvValue(s)
+		}
+		if envNested {
+			setEnvNested(data, name, value)
+			continue
+		}
+		data[name] = value
+	}
+	return data, nil
+}
+
+// splitEnv splits the environment variable v, as returned by os.Environ, into
+// its name and value on the first '=', so values can contain '=' (and
+// newlines). The '=' starting the names of Windows' per-drive variables (e.g.
+// "=C:=C:\dir") is part of the name. It returns false if v has no '='.
+func splitEnv(v string) (string, string, bool) {
+	if v == "" {
+		return "", "", false
+	}
+	i := strings.IndexByte(v[1:], '=') + 1
+	if i == 0 {
+		return "", "", false
+	}
+	return v[:i], v[i+1:], true
+}
+
+// parseEnvValue parses the value of an environment variable with
+// --env-parse-values: YAML scalars (numbers, booleans including yes/no and
+// on/off, and null or ~) and inline JSON objects and arrays. Other values,
+// including quoted strings and non-decimal numbers (e.g. 0755), are kept as
+// strings.
+func parseEnvValue(s string) interface{} {
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return s
+	}
+	if t[0] == '{' || t[0] == '[' {
+		var v interface{}
+		if json.Unmarshal([]byte(s), &v) != nil {
+			return s
+		}
+		return v
+	}
+	var doc yaml.Node
+	if yaml.Unmarshal([]byte(s), &doc) != nil || len(doc.Content) != 1 {
+		return s
+	}
+	// Quoted strings are kept as is
+	n := doc.Content[0]
+	if n.Kind != yaml.ScalarNode || n.Style != 0 {
+		return s
+	}
+	switch n.Tag {
+	case "!!int":
+		if !decimalRe.MatchString(n.Value) {
+			return s
+		}
+	case "!!float", "!!null", "!!bool":
+	case "!!str":
+		// YAML 1.1 booleans
+		switch strings.ToLower(n.Value) {
+		case "yes", "on":
+			return true
+		case "no", "off":
+			return false
+		}
+		return s
+	default:
+		return s
+	}
+	var v interface{}
+	if n.Decode(&v) != nil {
+		return s
+	}
+	return v
+}
+
+// decimalRe matches the integers parsed by --env-parse-values: decimal ones
+// without leading zeros, so e.g. 0755 or 0x1f are kept as strings.
+var decimalRe = regexp.MustCompile(`^[-+]?(?:0|[1-9][0-9]*)$`)
+
+// setEnvNested sets the environment variable name to value in data, splitting
+// the name on the --env-separator to build nested maps (e.g. DB__HOST is set
+// as .DB.HOST). Names with empty segments are set as is.
+func setEnvNested(data map[string]interface{}, name string, value interface{}) {
+	keys := strings.Split(name, envSeparator)
+	for _, k := range keys {
+		if k == "" {
+			data[name] = value
+			return
+		}
+	}
+	m := data
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[k] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = value
+}
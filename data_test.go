@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestSplitEnv(t *testing.T) {
+	tests := []struct {
+		v           string
+		name, value string
+		ok          bool
+	}{
+		{"HOME=/root", "HOME", "/root", true},
+		{"EMPTY=", "EMPTY", "", true},
+		{"EQUALS=a=b==", "EQUALS", "a=b==", true},
+		{"MULTILINE=a\nb", "MULTILINE", "a\nb", true},
+		{"GRÜSSE=héllo wörld", "GRÜSSE", "héllo wörld", true},
+		{"名前=値", "名前", "値", true},
+		{"ProgramFiles(x86)=C:\\Program Files (x86)", "ProgramFiles(x86)", "C:\\Program Files (x86)", true},
+		{"=C:=C:\\", "=C:", "C:\\", true},
+		{"=ExitCode=00000000", "=ExitCode", "00000000", true},
+		{"==", "=", "", true},
+		{"", "", "", false},
+		{"=", "", "", false},
+		{"NOVALUE", "", "", false},
+		{"=C:", "", "", false},
+	}
+	for _, tt := range tests {
+		name, value, ok := splitEnv(tt.v)
+		if name != tt.name || value != tt.value || ok != tt.ok {
+			t.Errorf("splitEnv(%q) = %q, %q, %v, want %q, %q, %v", tt.v, name, value, ok, tt.name, tt.value, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	// Time zones are embedded for dateInZone, as the Docker image has none
+	_ "time/tzdata"
+)
+
+// now returns the current time, or SOURCE_DATE_EPOCH with --reproducible.
+func now() time.Time {
+	return renderTime()
+}
+
+// toTime converts v to a time: a time, a number of seconds since the Unix
+// epoch, or a string in RFC 3339 format (e.g. 2024-01-02T15:04:05Z) or a date
+// (e.g. 2024-01-02).
+func toTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case int:
+		return time.Unix(int64(v), 0), nil
+	case int64:
+		return time.Unix(v, 0), nil
+	case float64:
+		return time.Unix(0, int64(v*float64(time.Second))), nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return toTime(f)
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse("2006-01-02", v); err == nil {
+			return t, nil
+		}
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(secs, 0), nil
+		}
+		return time.Time{}, fmt.Errorf("invalid date %q, must be in RFC 3339 format or a number of seconds since the Unix epoch", v)
+	}
+	return time.Time{}, fmt.Errorf("invalid date of type %T", v)
+}
+
+// date formats the date v (the current time if not given) with the go time
+// layout format, e.g. {{ now | date "2006-01-02" }}.
+func date(format string, v ...interface{}) (string, error) {
+	t := now()
+	if len(v) > 1 {
+		return "", fmt.Errorf("date takes at most one date, got %d", len(v))
+	}
+	if len(v) == 1 {
+		var err error
+		if t, err = toTime(v[0]); err != nil {
+			return "", err
+		}
+	}
+	return t.Format(format), nil
+}
+
+// dateInZone is like date, converting the date v to the time zone zone (e.g.
+// UTC or Europe/Paris) first.
+func dateInZone(format string, v interface{}, zone string) (string, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return "", err
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return "", err
+	}
+	return t.In(loc).Format(format), nil
+}
+
+// unixEpoch returns the number of seconds since the Unix epoch of the date v.
+func unixEpoch(v interface{}) (int64, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// dateModify returns the date v shifted by the duration d, e.g.
+// {{ now | dateModify "24h" }}.
+func dateModify(d interface{}, v interface{}) (time.Time, error) {
+	dur, err := duration(d)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := toTime(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Add(dur), nil
+}
+
+// duration converts v to a duration: a string in go duration format (e.g.
+// 1h30m) or a number of seconds. Durations are formatted like 1h30m0s, and
+// their methods convert them, e.g. {{ (duration "90m").Hours }}.
+func duration(v interface{}) (time.Duration, error) {
+	switch v := v.(type) {
+	case time.Duration:
+		return v, nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, nil
+		}
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			return duration(secs)
+		}
+		return 0, fmt.Errorf("invalid duration %q, must be like 1h30m or a number of seconds", v)
+	}
+	return 0, fmt.Errorf("invalid duration of type %T", v)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// decodeDotenv parses the contents of a .env file. Blank lines and lines
+// starting with '#' are ignored, and keys may be prefixed with 'export'.
+// Values can be unquoted (with trailing ' #' comments stripped), single
+// quoted (taken literally) or double quoted (supporting \n, \r, \t, \" and \\
+// escapes). Quoted values may span multiple lines.
+func decodeDotenv(s string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	line := 0
+	for len(s) > 0 {
+		line++
+		var l string
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			l, s = s[:i], s[i+1:]
+		} else {
+			l, s = s, ""
+		}
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		if strings.HasPrefix(l, "export ") || strings.HasPrefix(l, "export\t") {
+			l = strings.TrimSpace(l[len("export"):])
+		}
+		i := strings.IndexByte(l, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", line)
+		}
+		key := strings.TrimSpace(l[:i])
+		value := strings.TrimLeft(l[i+1:], " \t")
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = value[:j]
+			}
+			data[key] = strings.TrimSpace(value)
+			continue
+		}
+
+		// Quoted values may continue on the following lines, so scan the rest
+		// of the input for the closing quote.
+		quote := value[0]
+		rest := value[1:] + "\n" + s
+		var sb strings.Builder
+		end := -1
+		for j := 0; j < len(rest); j++ {
+			c := rest[j]
+			if c == quote {
+				end = j
+				break
+			}
+			if c == '\\' && quote == '"' && j+1 < len(rest) {
+				j++
+				switch rest[j] {
+				case 'n':
+					sb.WriteByte('\n')
+				case 'r':
+					sb.WriteByte('\r')
+				case 't':
+					sb.WriteByte('\t')
+				case '"', '\\', '$':
+					sb.WriteByte(rest[j])
+				default:
+					sb.WriteByte('\\')
+					sb.WriteByte(rest[j])
+				}
+				continue
+			}
+			sb.WriteByte(c)
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unterminated quoted value for %s", line, key)
+		}
+		data[key] = sb.String()
+
+		// Skip the lines consumed by the value and anything (e.g. a comment)
+		// after the closing quote.
+		consumed := rest[:end]
+		line += strings.Count(consumed, "\n")
+		rest = rest[end+1:]
+		if i := strings.IndexByte(rest, '\n'); i >= 0 {
+			if tail := strings.TrimSpace(rest[:i]); tail != "" && !strings.HasPrefix(tail, "#") {
+				return nil, fmt.Errorf("line %d: unexpected characters after quoted value for %s", line, key)
+			}
+			s = rest[i+1:]
+		} else {
+			s = ""
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// renderEach renders tpl once for every element of the list at --each in data.
+// The outputs are concatenated into OUTPUT or, with --output-name, written to
+// the file named by rendering that template against each element.
+func renderEach(tpl executor, data interface{}) error {
+	v, err := lookupPath(data, eachPath)
+	if err != nil {
+		return err
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("--each requires %s to be a list, got %T", eachPath, v)
+	}
+	var nameTpl executor
+	if outputName != "" {
+		if nameTpl, err = newTemplate("output-name", outputName); err != nil {
+			return fmt.Errorf("parsing --output-name: %w", err)
+		}
+	}
+	var buf bytes.Buffer
+	for i, item := range items {
+		start := time.Now()
+		b, err := execute(tpl, item)
+		if err == nil {
+			b, err = withHeader(inputName(), item, b)
+		}
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		b = convertNewlines(b, newlineStyle(nil))
+		if nameTpl == nil {
+			buf.Write(b)
+			continue
+		}
+		var name bytes.Buffer
+		if err := nameTpl.Execute(&name, item); err != nil {
+			return fmt.Errorf("element %d: rendering --output-name: %w", i, err)
+		}
+		dst := strings.TrimSpace(name.String())
+		if dst == "" {
+			return fmt.Errorf("element %d: --output-name rendered an empty file name", i)
+		}
+		if p := filepath.Clean(dst); p == "." || !isLocalPath(p) {
+			return fmt.Errorf("element %d: --output-name rendered invalid path %q, must be relative to the output directory", i, dst)
+		}
+		if err := writeReported(inputName(), filepath.Join(outputDir, dst), 0, b, start); err != nil {
+			return fmt.Errorf("element %d: writing %s: %w", i, dst, err)
+		}
+	}
+	if nameTpl == nil {
+		return writeResult(buf.Bytes())
+	}
+	return nil
+}
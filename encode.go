@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+)
+
+// b64enc encodes s in standard base64, e.g. for the data of Kubernetes
+// Secrets.
+func b64enc(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// b64dec decodes the standard base64 string s.
+func b64dec(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid base64: %w", err)
+	}
+	return string(b), nil
+}
+
+// hexenc encodes s in lowercase hexadecimal.
+func hexenc(s string) string {
+	return hex.EncodeToString([]byte(s))
+}
+
+// urlunquote decodes the URL query escaped string s, reverting the built-in
+// urlquery.
+func urlunquote(s string) (string, error) {
+	return url.QueryUnescape(s)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+// textEncodings are the names of the encodings of --input-encoding and
+// --data-encoding, mapped to their canonical names.
+var textEncodings = map[string]string{
+	"auto":     "auto",
+	"utf8":     "utf-8",
+	"utf16":    "utf-16",
+	"utf16le":  "utf-16le",
+	"utf16be":  "utf-16be",
+	"latin1":   "latin-1",
+	"iso88591": "latin-1",
+}
+
+// parseEncoding returns the canonical name of the encoding name (e.g. UTF-16LE,
+// utf16le or utf_16le), or false if it isn't supported.
+func parseEncoding(name string) (string, bool) {
+	name = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+	enc, ok := textEncodings[name]
+	return enc, ok
+}
+
+// decodeText decodes b from the encoding enc into UTF-8, stripping its byte
+// order mark (BOM). With auto, the encoding is detected from the BOM, and b is
+// UTF-8 if it has none.
+func decodeText(b []byte, enc string) ([]byte, error) {
+	if enc == "auto" || enc == "utf-16" {
+		switch {
+		case bytes.HasPrefix(b, utf8BOM):
+			enc = "utf-8"
+		case bytes.HasPrefix(b, utf16LEBOM):
+			enc = "utf-16le"
+		case bytes.HasPrefix(b, utf16BEBOM):
+			enc = "utf-16be"
+		case enc == "utf-16":
+			// Without a BOM, as written by Windows tools
+			enc = "utf-16le"
+		default:
+			return b, nil
+		}
+	}
+	switch enc {
+	case "utf-16le":
+		return decodeUTF16(bytes.TrimPrefix(b, utf16LEBOM), false)
+	case "utf-16be":
+		return decodeUTF16(bytes.TrimPrefix(b, utf16BEBOM), true)
+	case "latin-1":
+		var buf bytes.Buffer
+		for _, c := range b {
+			buf.WriteRune(rune(c))
+		}
+		return buf.Bytes(), nil
+	}
+	return bytes.TrimPrefix(b, utf8BOM), nil
+}
+
+// decodeUTF16 decodes the UTF-16 text b, big endian if be, into UTF-8.
+func decodeUTF16(b []byte, be bool) ([]byte, error) {
+	if len(b)%2 != 0 {
+		return nil, errors.New("invalid UTF-16 text: odd number of bytes")
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		if be {
+			units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			units[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(units))
+	for _, r := range utf16.Decode(units) {
+		buf.WriteRune(r)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeInput decodes the template text b with --input-encoding.
+func decodeInput(name string, b []byte) ([]byte, error) {
+	text, err := decodeText(b, inputEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return text, nil
+}
+
+// isUTF16 reports whether b, the start of a template, is UTF-16 text, which
+// contains null bytes but isn't binary.
+func isUTF16(b []byte) bool {
+	return strings.HasPrefix(inputEncoding, "utf-16") || bytes.HasPrefix(b, utf16LEBOM) || bytes.HasPrefix(b, utf16BEBOM)
+}
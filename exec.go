@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+)
+
+// process is the command run after rendering. In watch mode, it is sent
+// --reload-signal (or restarted with --restart) whenever the outputs are
+// rendered again.
+type process struct {
+	args []string
+	cmd  *exec.Cmd
+	done chan error
+}
+
+// startProcess starts args with the standard streams of datasubst.
+func startProcess(args []string) (*process, error) {
+	p := &process{args: args}
+	return p, p.start()
+}
+
+func (p *process) start() error {
+	p.cmd = exec.Command(p.args[0], p.args[1:]...)
+	p.cmd.Stdin, p.cmd.Stdout, p.cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := p.cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- p.cmd.Wait()
+	}()
+	p.done = done
+	return nil
+}
+
+// signal sends sig to the process.
+func (p *process) signal(sig os.Signal) {
+	_ = p.cmd.Process.Signal(sig)
+}
+
+// reload notifies the process that the outputs changed, either by sending it
+// --reload-signal or, with --restart, by stopping it and starting it again.
+func (p *process) reload() error {
+	if !restartFlag {
+		return p.cmd.Process.Signal(reloadSignal)
+	}
+	p.signal(stopSignal)
+	if err := <-p.done; err != nil && !errors.As(err, new(*exec.ExitError)) {
+		return err
+	}
+	return p.start()
+}
+
+// exited returns a channel receiving the result of the process, or nil if p is
+// nil (so it can be used in a select before the process is started).
+func (p *process) exited() <-chan error {
+	if p == nil {
+		return nil
+	}
+	return p.done
+}
+
+// commandStatus returns the exit status of a command from the result of
+// waiting for it.
+func commandStatus(err error) (int, error) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitStatus(exitErr), nil
+	}
+	return 0, err
+}
+
+// runCommand runs args, forwarding the signals received by datasubst, and
+// returns the command's exit status.
+func runCommand(args []string) (int, error) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardedSignals...)
+	defer signal.Stop(sigs)
+	p, err := startProcess(args)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		select {
+		case sig := <-sigs:
+			p.signal(sig)
+		case err := <-p.done:
+			return commandStatus(err)
+		}
+	}
+}
+
+// parseSignal returns the signal named name, with or without the SIG prefix
+// (e.g. HUP or SIGHUP).
+func parseSignal(name string) (os.Signal, error) {
+	if sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]; ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unsupported signal %q", name)
+}
+
+// runDataCommand runs the shell command line of an --exec-data source and
+// returns its standard output.
+func runDataCommand(command string) ([]byte, error) {
+	cmd := shellCommand(command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running %q: %v: %s", command, err, msg)
+		}
+		return nil, fmt.Errorf("running %q: %v", command, err)
+	}
+	return stdout.Bytes(), nil
+}
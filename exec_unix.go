@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// forwardedSignals are the signals relayed to the command run after rendering.
+var forwardedSignals = []os.Signal{
+	syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM,
+	syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGWINCH,
+}
+
+// signalNames are the signals that can be given to --reload-signal.
+var signalNames = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// stopSignal is the signal sent to stop the command when restarting it.
+var stopSignal os.Signal = syscall.SIGTERM
+
+// exitStatus returns the exit status of a command that failed, following the
+// shell convention of 128+N for commands killed by signal N.
+func exitStatus(err *exec.ExitError) int {
+	if ws, ok := err.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return err.ExitCode()
+}
+
+// shellCommand returns a command running command with the system shell.
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("/bin/sh", "-c", command)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// forwardedSignals are the signals relayed to the command run after rendering.
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// signalNames are the signals that can be given to --reload-signal.
+var signalNames = map[string]os.Signal{
+	"INT":  os.Interrupt,
+	"KILL": os.Kill,
+}
+
+// stopSignal is the signal sent to stop the command when restarting it.
+var stopSignal = os.Kill
+
+// exitStatus returns the exit status of a command that failed.
+func exitStatus(err *exec.ExitError) int {
+	return err.ExitCode()
+}
+
+// shellCommand returns a command running command with the system shell.
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
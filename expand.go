@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// templatedValue is a string value of the data containing template actions,
+// expanded with --expand-data.
+type templatedValue struct {
+	path string
+	tpl  executor
+	set  func(string)
+	// current is the value in the data
+	current string
+}
+
+// expandData renders the string values of data containing template actions
+// (e.g. url: "https://{{ .host }}:{{ .port }}") against data itself, with
+// --expand-data. Values can reference other templated values: every pass
+// renders all the values against the results of the previous one, until they
+// don't change, so a cycle of references is an error.
+func expandData(data interface{}) (interface{}, error) {
+	left := leftDelim
+	if left == "" {
+		left = "{{"
+	}
+	var values []*templatedValue
+	var err error
+	collectTemplated(data, "", left, func(path, s string, set func(string)) {
+		if err != nil {
+			return
+		}
+		tpl, e := newTemplate(path, s)
+		if e != nil {
+			err = fmt.Errorf("parsing data value %s: %w", path, e)
+			return
+		}
+		values = append(values, &templatedValue{path: path, tpl: tpl, set: set, current: s})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for pass := 0; ; pass++ {
+		results := make([]string, len(values))
+		for i, v := range values {
+			// Not execute: --chomp and --validate apply to the outputs only
+			var buf bytes.Buffer
+			if err := v.tpl.Execute(&buf, data); err != nil {
+				return nil, fmt.Errorf("expanding data value %s: %w", v.path, describeTemplateError(err, v.tpl))
+			}
+			results[i] = buf.String()
+		}
+		var changed []string
+		for i, v := range values {
+			if results[i] != v.current {
+				changed = append(changed, v.path)
+				v.current = results[i]
+				v.set(results[i])
+			}
+		}
+		if len(changed) == 0 {
+			return data, nil
+		}
+		// Chains of references are expanded in at most one pass per value
+		if pass > len(values) {
+			return nil, fmt.Errorf("expanding data: the values %s reference each other in a cycle", strings.Join(changed, ", "))
+		}
+	}
+}
+
+// collectTemplated calls fn with the path, value and setter of each string
+// value of v, at path, containing the left delimiter left.
+func collectTemplated(v interface{}, path, left string, fn func(path, s string, set func(string))) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range orderedKeys(v) {
+			k := k
+			p := path + "." + k
+			if s, ok := v[k].(string); ok {
+				if strings.Contains(s, left) {
+					fn(p, s, func(s string) { v[k] = s })
+				}
+				continue
+			}
+			collectTemplated(v[k], p, left, fn)
+		}
+	case []interface{}:
+		for i := range v {
+			i := i
+			p := path + "[" + strconv.Itoa(i) + "]"
+			if s, ok := v[i].(string); ok {
+				if strings.Contains(s, left) {
+					fn(p, s, func(s string) { v[i] = s })
+				}
+				continue
+			}
+			collectTemplated(v[i], p, left, fn)
+		}
+	}
+}
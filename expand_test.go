@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandData(t *testing.T) {
+	tests := []struct {
+		data string
+		want map[string]interface{}
+		err  string
+	}{
+		{
+			data: "host: example.com\nurl: 'https://{{ .host }}'",
+			want: map[string]interface{}{"host": "example.com", "url": "https://example.com"},
+		},
+		{
+			// Chains of references are expanded whatever their order
+			data: "a: '{{ .b }}/a'\nb: '{{ .c }}/b'\nc: c",
+			want: map[string]interface{}{"a": "c/b/a", "b": "c/b", "c": "c"},
+		},
+		{
+			data: "l: ['{{ .m.x }}', plain]\nm: {x: '{{ len .l }}'}",
+			want: map[string]interface{}{"l": []interface{}{"2", "plain"}, "m": map[string]interface{}{"x": "2"}},
+		},
+		{
+			data: "a: '{{ .a }}'",
+			want: map[string]interface{}{"a": "{{ .a }}"},
+		},
+		{
+			data: "a: '{{ .b }}x'\nb: '{{ .a }}y'",
+			err:  "the values .a, .b reference each other in a cycle",
+		},
+		{
+			data: "a: '{{ .a }}x'",
+			err:  "the values .a reference each other in a cycle",
+		},
+		{
+			data: "a: '{{ .b'",
+			err:  "parsing data value .a",
+		},
+	}
+	for _, tt := range tests {
+		setTestOptions(t)
+		got, err := expandData(yamlData(t, tt.data))
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("expandData(%q): got error %v, want %s", tt.data, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("expandData(%q): %v", tt.data, err)
+		case tt.err == "" && !reflect.DeepEqual(got, tt.want):
+			t.Errorf("expandData(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// fileFuncName is the template function writing additional output files.
+const fileFuncName = "file"
+
+// fileFunc implements {{ file "path" content }}, which writes content to path
+// under --output-dir (with the same options as the other outputs) and renders
+// nothing, so a single template can generate several files, e.g.
+//
+//	{{ range .services }}{{ file (printf "%s.yaml" .name) (include "service" .) }}{{ end }}
+func fileFunc(path string, content interface{}) (string, error) {
+	if outputDir == "" {
+		return "", errors.New("--output-dir is required")
+	}
+	p := filepath.Clean(path)
+	if path == "" || p == "." || !isLocalPath(p) {
+		return "", fmt.Errorf("invalid path %q, must be relative to the output directory", path)
+	}
+	b := convertNewlines([]byte(fmt.Sprint(content)), newlineStyle(nil))
+	if err := writeReported("", filepath.Join(outputDir, p), 0, b, time.Now()); err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	return "", nil
+}
+
+// includeFunc returns the include function of tpl, which renders the template
+// named name of its set against data and returns the result, so it can be
+// piped (e.g. to file or indent), unlike {{ template }}.
+func includeFunc(tpl *template.Template) func(name string, data interface{}) (string, error) {
+	return func(name string, data interface{}) (string, error) {
+		var buf bytes.Buffer
+		if err := tpl.ExecuteTemplate(&buf, name, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+}
+
+// tplFuncName is the name of the function rendering strings as templates.
+const tplFuncName = "tpl"
+
+// tplFunc returns the tpl function of tpl, parsed with opts, which renders
+// the string text (e.g. a value of the data) as a template against data and
+// returns the result, like Helm's tpl, e.g. {{ tpl .message . }}. The text
+// can use the templates of the set of tpl, and its random source rand.
+func tplFunc(tpl *template.Template, opts templateOptions, rand *randomSource) func(text string, data interface{}) (string, error) {
+	return func(text string, data interface{}) (string, error) {
+		t := newTemplateSet(opts, tplFuncName, rand)
+		expanded, err := expandTemplateText(text, opts.leftDelim, opts.rightDelim)
+		if err != nil {
+			return "", err
+		}
+		if _, err := t.Parse(expanded); err != nil {
+			return "", err
+		}
+		rewriteTemplates(t, opts)
+		// The templates of the set are already rewritten
+		for _, o := range tpl.Templates() {
+			if o.Tree != nil && t.Lookup(o.Name()) == nil {
+				if _, err := t.AddParseTree(o.Name(), o.Tree); err != nil {
+					return "", err
+				}
+			}
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+}
+
+// writesFiles reports whether a template of the set of tpl calls file.
+// Outputs left blank by such templates are not written.
+func writesFiles(tpl executor) bool {
+	if t, ok := tpl.(frontMatterTemplate); ok {
+		tpl = t.executor
+	}
+	t, ok := tpl.(*template.Template)
+	if !ok {
+		return false
+	}
+	for _, t := range t.Templates() {
+		if t.Tree != nil && callsFunc(t.Tree.Root, fileFuncName) {
+			return true
+		}
+	}
+	return false
+}
+
+// callsFunc reports whether the function name is called within node.
+func callsFunc(node parse.Node, name string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, c := range n.Nodes {
+			if callsFunc(c, name) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return callsFunc(n.Pipe, name)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, c := range n.Cmds {
+			if callsFunc(c, name) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			if callsFunc(a, name) {
+				return true
+			}
+		}
+	case *parse.IdentifierNode:
+		return n.Ident == name
+	case *parse.IfNode:
+		return callsFunc(n.Pipe, name) || callsFunc(n.List, name) || callsFunc(n.ElseList, name)
+	case *parse.RangeNode:
+		return callsFunc(n.Pipe, name) || callsFunc(n.List, name) || callsFunc(n.ElseList, name)
+	case *parse.WithNode:
+		return callsFunc(n.Pipe, name) || callsFunc(n.List, name) || callsFunc(n.ElseList, name)
+	case *parse.TemplateNode:
+		return callsFunc(n.Pipe, name)
+	}
+	return false
+}
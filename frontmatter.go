@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter is the optional YAML block at the top of a template, between two
+// '---' lines, read with --front-matter so templates can describe themselves:
+//
+//	---
+//	defaults: {port: 8080}
+//	delimiters: '[[:]]'
+//	strict: true
+//	output: app.conf
+//	---
+//	listen [[ .port ]];
+type frontMatter struct {
+	// Defaults are merged under the data
+	Defaults   map[string]interface{} `yaml:"defaults"`
+	Delimiters string                 `yaml:"delimiters"`
+	Strict     bool                   `yaml:"strict"`
+	MissingKey string                 `yaml:"missing-key"`
+	// Output is relative to the template's directory, or to the directory
+	// of its output in directory mode, and can't escape it
+	Output string `yaml:"output"`
+
+	// lines is the number of lines of the front matter, including the
+	// '---' lines
+	lines int
+}
+
+// splitFrontMatter returns the front matter at the top of text and the rest
+// of text. The front matter is nil if text doesn't start with a '---' line or
+// without --front-matter.
+func splitFrontMatter(text string) (*frontMatter, string, error) {
+	if !frontMatterFlag {
+		return nil, text, nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) < 2 || strings.TrimRight(lines[0], "\r\n") != "---" {
+		return nil, text, nil
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\r\n") != "---" {
+			continue
+		}
+		fm := &frontMatter{}
+		dec := yaml.NewDecoder(strings.NewReader(strings.Join(lines[1:i], "")))
+		dec.KnownFields(true)
+		if err := dec.Decode(fm); err != nil && err != io.EOF {
+			return nil, "", fmt.Errorf("parsing front matter: %w", err)
+		}
+		if p := filepath.Clean(fm.Output); fm.Output != "" && (p == "." || !isLocalPath(p)) {
+			return nil, "", fmt.Errorf("front matter: invalid output %q, must be relative to the template's directory", fm.Output)
+		}
+		fm.lines = i + 1
+		return fm, strings.Join(lines[i+1:], ""), nil
+	}
+	return nil, "", errors.New("front matter is not closed by a '---' line")
+}
+
+// options returns the global template options overridden by the front matter.
+func (fm *frontMatter) options() (templateOptions, error) {
+	opts := globalTemplateOptions()
+	if fm == nil {
+		return opts, nil
+	}
+	opts.lineOffset = fm.lines
+	if fm.Delimiters != "" {
+		var err error
+		if opts.leftDelim, opts.rightDelim, err = parseDelimiters(fm.Delimiters); err != nil {
+			return opts, fmt.Errorf("front matter: %w", err)
+		}
+	}
+	if fm.Strict {
+		opts.missingKey = "error"
+	}
+	switch fm.MissingKey {
+	case "":
+	case "error", "zero", "warn", "default":
+		opts.missingKey = fm.MissingKey
+	default:
+		return opts, fmt.Errorf("front matter: invalid missing key policy %q", fm.MissingKey)
+	}
+	return opts, nil
+}
+
+// outputPath returns the output path set by the front matter, relative to
+// dir, or "" if there is none.
+func (fm *frontMatter) outputPath(dir string) string {
+	if fm == nil || fm.Output == "" {
+		return ""
+	}
+	return filepath.Join(dir, fm.Output)
+}
+
+// frontMatterTemplate is a template rendered with the defaults of its front
+// matter under the data.
+type frontMatterTemplate struct {
+	executor
+	front *frontMatter
+}
+
+func (t frontMatterTemplate) Execute(w io.Writer, data interface{}) error {
+	if len(t.front.Defaults) > 0 {
+		addSecrets(t.front.Defaults)
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		var err error
+		data, err = mergeData(copyMaps(t.front.Defaults), data, mergeStrategy == "deep")
+		if err != nil {
+			return fmt.Errorf("merging front matter defaults: %w", err)
+		}
+	}
+	return t.executor.Execute(w, data)
+}
+
+// parseTemplateFile parses the template named name, with the options and
+// defaults of its front matter, if any.
+func parseTemplateFile(name, text string) (executor, *frontMatter, error) {
+	fm, text, err := splitFrontMatter(text)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts, err := fm.options()
+	if err != nil {
+		return nil, nil, err
+	}
+	tpl, err := parseTemplateWith(opts, name, text)
+	if err != nil || fm == nil {
+		return tpl, fm, err
+	}
+	return frontMatterTemplate{executor: tpl, front: fm}, fm, nil
+}
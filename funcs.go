@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateFuncs returns the functions available to templates in addition to
+// the go template built-in functions.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"toJson":        toJSON,
+		"toPrettyJson":  toPrettyJSON,
+		"toYaml":        toYAML,
+		"indent":        indent,
+		"nindent":       nindent,
+		"required":      required,
+		"fail":          fail,
+		"default":       defaultValue,
+		"coalesce":      coalesce,
+		"get":           get,
+		"pluck":         pluck,
+		"camelcase":     camelCase,
+		"snakecase":     snakeCase,
+		"kebabcase":     kebabCase,
+		"title":         title,
+		"upper":         strings.ToUpper,
+		"lower":         strings.ToLower,
+		"b64enc":        b64enc,
+		"b64dec":        b64dec,
+		"hexenc":        hexenc,
+		"urlunquote":    urlunquote,
+		"sha256sum":     sha256sum,
+		"sha1sum":       sha1sum,
+		"md5sum":        md5sum,
+		"adler32":       adler32sum,
+		"shquote":       shquote,
+		"shjoin":        shjoin,
+		"jsonEscape":    jsonEscape,
+		"yamlQuote":     yamlQuote,
+		"now":           now,
+		"date":          date,
+		"dateInZone":    dateInZone,
+		"dateModify":    dateModify,
+		"unixEpoch":     unixEpoch,
+		"duration":      duration,
+		"dict":          dict,
+		"list":          list,
+		"merge":         merge,
+		"keys":          keys,
+		"values":        values,
+		"has":           has,
+		"pick":          pick,
+		"omit":          omit,
+		"sortBy":        sortBy,
+		"uniq":          uniq,
+		"first":         first,
+		"last":          last,
+		"semver":        semver,
+		"semverCompare": semverCompare,
+		"cidrhost":      cidrhost,
+		"cidrsubnet":    cidrsubnet,
+		"cidrsubnets":   cidrsubnets,
+		"cidrnetmask":   cidrnetmask,
+		"cidrContains":  cidrContains,
+		"isIP":          isIP,
+		"isIPv4":        isIPv4,
+		"isIPv6":        isIPv6,
+		"isCIDR":        isCIDR,
+		fileFuncName:    fileFunc,
+	}
+}
+
+// toJSON encodes v as compact JSON.
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(withKeyOrder(v))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// toPrettyJSON encodes v as JSON indented with two spaces.
+func toPrettyJSON(v interface{}) (string, error) {
+	b, err := json.MarshalIndent(withKeyOrder(v), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// toYAML encodes v as YAML indented with two spaces, without the trailing
+// newline so it can be combined with indent and nindent.
+func toYAML(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(withKeyOrder(v)); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// indent prefixes every line of s with n spaces.
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// nindent is like indent but also adds a newline before s, so it can be used
+// right after a YAML key.
+func nindent(n int, s string) string {
+	return "\n" + indent(n, s)
+}
+
+// required returns v, or an error with the given message if v is missing or an
+// empty string.
+func required(msg string, v interface{}) (interface{}, error) {
+	if s, ok := v.(string); v == nil || ok && s == "" {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+// fail always returns an error with the given message.
+func fail(msg string) (string, error) {
+	return "", errors.New(msg)
+}
+
+// defaultValue returns v, or def if v is empty.
+func defaultValue(def, v interface{}) interface{} {
+	if isEmpty(v) {
+		return def
+	}
+	return v
+}
+
+// coalesce returns the first non-empty value, or nil if all of them are empty.
+func coalesce(v ...interface{}) interface{} {
+	for _, e := range v {
+		if !isEmpty(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+// get returns the value at the nested keys of m, or nil if a key is missing.
+// Unlike fields, keys can contain dashes, dots or spaces, e.g.
+// {{ get . "app-config" "log.level" }}.
+func get(m interface{}, keys ...string) (interface{}, error) {
+	v := m
+	for _, k := range keys {
+		if v == nil {
+			return nil, nil
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot get key %q of %T", k, v)
+		}
+		e := rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()))
+		if !e.IsValid() {
+			return nil, nil
+		}
+		v = e.Interface()
+	}
+	return v, nil
+}
+
+// pluck returns the values of key in each of maps that have it, a list
+// argument standing for its elements, e.g. {{ pluck "name" .services }}.
+func pluck(key string, maps ...interface{}) ([]interface{}, error) {
+	values := []interface{}{}
+	for _, m := range maps {
+		elems, ok := m.([]interface{})
+		if !ok {
+			elems = []interface{}{m}
+		}
+		for _, e := range elems {
+			v, err := get(e, key)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				values = append(values, v)
+			}
+		}
+	}
+	return values, nil
+}
+
+// isEmpty reports whether v is nil, false, zero, or an empty string, list or
+// map.
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
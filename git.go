@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// gitDataKey is the key the git metadata of --git-data is exposed under.
+const gitDataKey = "Git"
+
+// gitDataFlag is the --git-data flag, which can be given without a value to
+// use the repository containing the current directory.
+type gitDataFlag struct {
+	repo *string
+}
+
+func (f gitDataFlag) String() string {
+	return ""
+}
+
+func (f gitDataFlag) Set(v string) error {
+	switch v {
+	case "true":
+		v = "."
+	case "false":
+		v = ""
+	}
+	*f.repo = v
+	return nil
+}
+
+func (f gitDataFlag) IsBoolFlag() bool {
+	return true
+}
+
+// loadGitData returns the metadata of the HEAD commit of the git repository
+// at repo: its full and abbreviated hash, the current branch (empty when
+// detached), the tag pointing at it (if any), the output of 'git describe' and
+// whether the working tree has uncommitted changes.
+func loadGitData(repo string) (map[string]interface{}, error) {
+	commit, err := git(repo, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	shortCommit, err := git(repo, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	branch, err := git(repo, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if branch == "HEAD" {
+		branch = ""
+	}
+	describe, err := git(repo, "describe", "--tags", "--always", "--dirty")
+	if err != nil {
+		return nil, err
+	}
+	status, err := git(repo, "status", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	// Fails when no tag points at HEAD
+	tag, _ := git(repo, "describe", "--tags", "--exact-match", "HEAD")
+	return map[string]interface{}{
+		"Commit":      commit,
+		"ShortCommit": shortCommit,
+		"Branch":      branch,
+		"Tag":         tag,
+		"Describe":    describe,
+		"Dirty":       status != "",
+	}, nil
+}
+
+// git runs a git command in the repository at repo and returns its trimmed
+// output.
+func git(repo string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, msg)
+		}
+		return "", fmt.Errorf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matchGlob reports whether the slash or OS separated relative path rel
+// matches pattern. Patterns use path.Match syntax plus '**', which matches any
+// number of directories (e.g. vendor/** or **/testdata/*). Patterns without a
+// '/' are matched against the base name of rel, so *.tmpl matches templates at
+// any depth.
+func matchGlob(pattern, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchSegments(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+// matchAny reports whether rel matches any of the patterns.
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, rel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// runTestCases runs the golden-file test cases of the test command: every
+// directory under --cases holding a template file (template or template.*)
+// is a case, rendered against its data files (data.*, merged in name order
+// over the data sources, if any) and compared with its expected file
+// (expected or expected.*). It returns a report with a diff for every failed
+// case, and the number of failed cases.
+func runTestCases(dir string) ([]byte, int, error) {
+	var base interface{} = make(map[string]interface{})
+	if len(dataSources) > 0 || envFlag || gitRepo != "" {
+		var err error
+		if base, err = loadData(); err != nil {
+			return nil, 0, fmt.Errorf("opening data file: %w", err)
+		}
+	}
+	var cases []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && len(caseFiles(path, "template")) > 0 {
+			cases = append(cases, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(cases) == 0 {
+		return nil, 0, fmt.Errorf("no test cases found in %s", dir)
+	}
+
+	var report bytes.Buffer
+	failed := 0
+	for _, c := range cases {
+		name, err := filepath.Rel(dir, c)
+		if err != nil {
+			return nil, 0, err
+		}
+		diff, err := runTestCase(c, base)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(&report, "FAIL %s: %v\n", name, err)
+		case diff != "":
+			failed++
+			fmt.Fprintf(&report, "FAIL %s\n%s", name, diff)
+		default:
+			fmt.Fprintf(&report, "ok   %s\n", name)
+		}
+	}
+	fmt.Fprintf(&report, "%d passed, %d failed\n", len(cases)-failed, failed)
+	return report.Bytes(), failed, nil
+}
+
+// runTestCase renders the test case in dir against its data merged over base,
+// returning a unified diff from its expected output, or "" if they match.
+func runTestCase(dir string, base interface{}) (string, error) {
+	tplPath, err := caseFile(dir, "template")
+	if err != nil {
+		return "", err
+	}
+	expectedPath, err := caseFile(dir, "expected")
+	if err != nil {
+		return "", err
+	}
+	dataPaths, err := filepath.Glob(filepath.Join(dir, "data.*"))
+	if err != nil {
+		return "", err
+	}
+	data := copyMaps(base)
+	for _, path := range dataPaths {
+		format, err := dataSourceFormat(path)
+		if err != nil {
+			return "", err
+		}
+		d, err := parseDataSource(dataSource{format: format, path: path})
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		if data, err = mergeData(data, d, mergeStrategy == "deep"); err != nil {
+			return "", fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+	}
+
+	text, err := ioutil.ReadFile(filepath.Clean(tplPath))
+	if err != nil {
+		return "", err
+	}
+	if text, err = decodeInput(filepath.Base(tplPath), text); err != nil {
+		return "", err
+	}
+	tpl, _, err := parseTemplateFile(filepath.Base(tplPath), string(text))
+	if err != nil {
+		return "", fmt.Errorf("parsing template: %w", err)
+	}
+	got, err := execute(tpl, data)
+	if err != nil {
+		return "", fmt.Errorf("rendering template: %w", err)
+	}
+	expected, err := ioutil.ReadFile(filepath.Clean(expectedPath))
+	if err != nil {
+		return "", err
+	}
+	if bytes.Equal(got, expected) {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(expected)),
+		B:        splitLines(string(got)),
+		FromFile: expectedPath,
+		ToFile:   "rendered",
+		Context:  3,
+	})
+}
+
+// caseFile returns the path of the file of the test case in dir named name,
+// with or without an extension.
+func caseFile(dir, name string) (string, error) {
+	found := caseFiles(dir, name)
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no %s file", name)
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("several %s files: %s", name, strings.Join(found, ", "))
+}
+
+// caseFiles returns the paths of the regular files in dir named name, with or
+// without an extension.
+func caseFiles(dir, name string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, name+"*"))
+	var found []string
+	for _, m := range matches {
+		base := filepath.Base(m)
+		if base != name && !strings.HasPrefix(base, name+".") {
+			continue
+		}
+		if info, err := os.Stat(m); err == nil && info.Mode().IsRegular() {
+			found = append(found, m)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
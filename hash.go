@@ -0,0 +1,34 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/adler32"
+)
+
+// sha256sum returns the hex encoded SHA-256 digest of s, e.g. for checksum
+// annotations restarting pods when their configuration changes.
+func sha256sum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// sha1sum returns the hex encoded SHA-1 digest of s.
+func sha1sum(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// md5sum returns the hex encoded MD5 digest of s.
+func md5sum(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// adler32sum returns the hex encoded Adler-32 checksum of s.
+func adler32sum(s string) string {
+	return fmt.Sprintf("%08x", adler32.Checksum([]byte(s)))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// decodeHCL parses the contents of an HCL file, such as a Terraform variables
+// file (terraform.tfvars). Attributes become keys, and labelled blocks (e.g.
+// variable "region" { ... }) become nested maps (.variable.region). Repeated
+// blocks with the same labels are merged. Values must be constant: references
+// to variables and function calls aren't supported.
+func decodeHCL(b []byte) (map[string]interface{}, error) {
+	f, diags := hclsyntax.ParseConfig(b, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body, ok := f.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("unexpected HCL body %T", f.Body)
+	}
+	return hclObject(body)
+}
+
+func hclObject(body *hclsyntax.Body) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+	})
+	for _, attr := range attrs {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		v, err := hclValue(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", attr.SrcRange, err)
+		}
+		data[attr.Name] = v
+	}
+	for _, block := range body.Blocks {
+		o, err := hclObject(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{} = o
+		// Labelled blocks are nested under each of their labels
+		for i := len(block.Labels) - 1; i >= 0; i-- {
+			v = map[string]interface{}{block.Labels[i]: v}
+		}
+		if v, err = mergeData(data[block.Type], v, true); err != nil {
+			return nil, fmt.Errorf("block %q: %w", block.Type, err)
+		}
+		data[block.Type] = v
+	}
+	return data, nil
+}
+
+func hclValue(val cty.Value) (interface{}, error) {
+	switch {
+	case val.IsNull():
+		return nil, nil
+	case !val.IsKnown():
+		return nil, errors.New("unknown HCL value")
+	}
+	t := val.Type()
+	switch {
+	case t == cty.String:
+		return val.AsString(), nil
+	case t == cty.Bool:
+		return val.True(), nil
+	case t == cty.Number:
+		f := val.AsBigFloat()
+		if i, acc := f.Int64(); acc == big.Exact {
+			return i, nil
+		}
+		n, _ := f.Float64()
+		return n, nil
+	case t.IsListType(), t.IsTupleType(), t.IsSetType():
+		l := make([]interface{}, 0, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			v, err := hclValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		return l, nil
+	case t.IsMapType(), t.IsObjectType():
+		m := make(map[string]interface{}, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			k, elem := it.Element()
+			v, err := hclValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[k.AsString()] = v
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported HCL value of type %s", t.FriendlyName())
+}
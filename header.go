@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// withHeader prepends the --header template, rendered for the output of the
+// template at src, to b. Besides the top-level keys of data, the header can
+// use .__source (the template path), .__data (the data source paths) and
+// .__timestamp (the rendering time, in RFC 3339 format, or SOURCE_DATE_EPOCH
+// with --reproducible).
+func withHeader(src string, data interface{}, b []byte) ([]byte, error) {
+	if header == "" {
+		return b, nil
+	}
+	tpl, err := newTemplate("header", header)
+	if err != nil {
+		return nil, fmt.Errorf("parsing --header: %w", err)
+	}
+	vars := make(map[string]interface{})
+	if m, ok := data.(map[string]interface{}); ok {
+		for k, v := range m {
+			vars[k] = v
+		}
+	}
+	paths := make([]string, len(dataSources))
+	for i, s := range dataSources {
+		paths[i] = s.path
+		if isURL(s.path) {
+			// Credentials in URLs must not end up in the outputs
+			paths[i] = redactURL(s.path)
+		}
+	}
+	vars["__source"] = src
+	vars["__data"] = strings.Join(paths, ", ")
+	vars["__timestamp"] = renderTime().Format(time.RFC3339)
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, vars); err != nil {
+		return nil, fmt.Errorf("rendering --header: %w", err)
+	}
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	buf.Write(b)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName is the name of the file listing gitignore-style patterns of
+// files to skip, read from the root of an input directory.
+const ignoreFileName = ".datasubstignore"
+
+// ignoreRule is a pattern read from an ignore file.
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// readIgnoreFile reads the ignore file at the root of dir, if any. Blank lines
+// and lines starting with '#' are ignored, '!' negates a pattern and a trailing
+// '/' only matches directories. Patterns are matched as with --exclude, so a
+// leading '/' anchors a pattern to the root of dir.
+func readIgnoreFile(dir string) ([]ignoreRule, error) {
+	f, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := strings.TrimRight(scanner.Text(), " \t\r")
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		var r ignoreRule
+		if strings.HasPrefix(l, "!") {
+			r.negate, l = true, l[1:]
+		}
+		if strings.HasSuffix(l, "/") {
+			r.dirOnly, l = true, strings.TrimRight(l, "/")
+		}
+		if l != "" && l != "/" {
+			r.pattern = l
+			rules = append(rules, r)
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// ignored reports whether the relative path rel is ignored by rules, the last
+// matching rule taking precedence. The ignore file itself is always ignored.
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	if rel == ignoreFileName {
+		return true
+	}
+	skip := false
+	for _, r := range rules {
+		if (!r.dirOnly || isDir) && matchGlob(r.pattern, rel) {
+			skip = !r.negate
+		}
+	}
+	return skip
+}
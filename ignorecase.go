@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// ignoreCaseFuncName is the function the field accesses of templates are
+// rewritten to with --ignore-case.
+const ignoreCaseFuncName = "_field"
+
+// ignoreCaseFuncs returns the functions needed by templates rewritten with
+// ignoreCaseTemplate.
+func ignoreCaseFuncs(policy string) template.FuncMap {
+	return template.FuncMap{
+		ignoreCaseFuncName: func(v interface{}, keys ...string) (interface{}, error) {
+			for _, k := range keys {
+				var err error
+				if v, err = lookupField(v, k, policy == "error"); err != nil {
+					return nil, err
+				}
+			}
+			return v, nil
+		},
+	}
+}
+
+// lookupField returns the field or key name of v, ignoring case if v has no
+// exact match. Keys matching in several cases are resolved in sorted order.
+// Missing keys are nil (or the zero value of the map's elements), or an error
+// if strict.
+func lookupField(v interface{}, name string, strict bool) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("nil pointer evaluating %s.%s", rv.Type(), name)
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil, fmt.Errorf("nil data; no entry for key %q", name)
+	case reflect.Struct:
+		f := rv.FieldByName(name)
+		if !f.IsValid() {
+			f = rv.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+		}
+		if !f.IsValid() || !f.CanInterface() {
+			return nil, fmt.Errorf("can't evaluate field %s in type %s", name, rv.Type())
+		}
+		return f.Interface(), nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if e := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key())); e.IsValid() {
+			return e.Interface(), nil
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			if strings.EqualFold(k.String(), name) {
+				return rv.MapIndex(k).Interface(), nil
+			}
+		}
+		if strict {
+			return nil, fmt.Errorf("map has no entry for key %q", name)
+		}
+		if rv.Type().Elem().Kind() == reflect.Interface {
+			return nil, nil
+		}
+		return reflect.Zero(rv.Type().Elem()).Interface(), nil
+	}
+	return nil, fmt.Errorf("can't evaluate field %s in type %s", name, rv.Type())
+}
+
+// ignoreCaseTemplate rewrites the field accesses of tpl (e.g. .apiKey, $x.apiKey
+// or (pipeline).apiKey) to calls of ignoreCaseFuncName, so keys are looked up
+// ignoring case: .apiKey, .APIKEY and .apikey all match the same key.
+func ignoreCaseTemplate(tpl *template.Template) {
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil && t.Tree.Root != nil {
+			ignoreCaseNode(t.Tree, t.Tree.Root)
+		}
+	}
+}
+
+func ignoreCaseNode(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			ignoreCaseNode(tree, c)
+		}
+	case *parse.ActionNode:
+		ignoreCasePipe(tree, n.Pipe)
+	case *parse.IfNode:
+		ignoreCasePipe(tree, n.Pipe)
+		ignoreCaseNode(tree, n.List)
+		ignoreCaseNode(tree, n.ElseList)
+	case *parse.RangeNode:
+		ignoreCasePipe(tree, n.Pipe)
+		ignoreCaseNode(tree, n.List)
+		ignoreCaseNode(tree, n.ElseList)
+	case *parse.WithNode:
+		ignoreCasePipe(tree, n.Pipe)
+		ignoreCaseNode(tree, n.List)
+		ignoreCaseNode(tree, n.ElseList)
+	case *parse.TemplateNode:
+		ignoreCasePipe(tree, n.Pipe)
+	}
+}
+
+func ignoreCasePipe(tree *parse.Tree, pipe *parse.PipeNode) {
+	if pipe == nil {
+		return
+	}
+	for _, cmd := range pipe.Cmds {
+		for i, arg := range cmd.Args {
+			// Fields given arguments are method calls
+			if _, ok := arg.(*parse.FieldNode); ok && i == 0 && len(cmd.Args) > 1 {
+				continue
+			}
+			cmd.Args[i] = ignoreCaseArg(tree, arg)
+		}
+	}
+}
+
+func ignoreCaseArg(tree *parse.Tree, node parse.Node) parse.Node {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return fieldCall(tree, n.Pos, &parse.DotNode{NodeType: parse.NodeDot, Pos: n.Pos}, n.Ident)
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 {
+			v := &parse.VariableNode{NodeType: parse.NodeVariable, Pos: n.Pos, Ident: n.Ident[:1]}
+			return fieldCall(tree, n.Pos, v, n.Ident[1:])
+		}
+	case *parse.ChainNode:
+		return fieldCall(tree, n.Pos, ignoreCaseArg(tree, n.Node), n.Field)
+	case *parse.PipeNode:
+		ignoreCasePipe(tree, n)
+	}
+	return node
+}
+
+// fieldCall returns the pipeline (_field recv "key"...) looking up keys in the
+// value of recv.
+func fieldCall(tree *parse.Tree, pos parse.Pos, recv parse.Node, keys []string) parse.Node {
+	args := []parse.Node{parse.NewIdentifier(ignoreCaseFuncName).SetTree(tree).SetPos(pos), recv}
+	for _, k := range keys {
+		args = append(args, &parse.StringNode{NodeType: parse.NodeString, Pos: pos, Quoted: strconv.Quote(k), Text: k})
+	}
+	return &parse.PipeNode{
+		NodeType: parse.NodePipe,
+		Pos:      pos,
+		Cmds:     []*parse.CommandNode{{NodeType: parse.NodeCommand, Pos: pos, Args: args}},
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupField(t *testing.T) {
+	type creds struct{ APIKey string }
+	tests := []struct {
+		v      interface{}
+		name   string
+		strict bool
+		want   interface{}
+		err    string
+	}{
+		{map[string]interface{}{"apiKey": "a"}, "apiKey", false, "a", ""},
+		{map[string]interface{}{"apiKey": "a"}, "APIKEY", false, "a", ""},
+		{map[string]interface{}{"APIKEY": "b", "apiKey": "a", "apikey": "c"}, "ApiKey", false, "b", ""},
+		{map[string]interface{}{"APIKEY": "b", "apiKey": "a"}, "apiKey", false, "a", ""},
+		{map[string]interface{}{}, "nope", false, nil, ""},
+		{map[string]interface{}{}, "nope", true, nil, `map has no entry for key "nope"`},
+		{map[string]int{}, "nope", false, 0, ""},
+		{creds{APIKey: "s"}, "apikey", false, "s", ""},
+		{&creds{APIKey: "s"}, "APIKey", false, "s", ""},
+		{creds{}, "token", false, nil, "can't evaluate field token"},
+		{nil, "a", false, nil, `nil data; no entry for key "a"`},
+		{"string", "a", false, nil, "can't evaluate field a in type string"},
+	}
+	for _, tt := range tests {
+		got, err := lookupField(tt.v, tt.name, tt.strict)
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("lookupField(%v, %q, %v): got error %v, want %s", tt.v, tt.name, tt.strict, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("lookupField(%v, %q, %v): %v", tt.v, tt.name, tt.strict, err)
+		case tt.err == "" && got != tt.want:
+			t.Errorf("lookupField(%v, %q, %v) = %v, want %v", tt.v, tt.name, tt.strict, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreCase(t *testing.T) {
+	data := map[string]interface{}{
+		"apiKey": "a",
+		"DB":     map[string]interface{}{"Host": "h", "ports": []interface{}{1, 2}},
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"{{ .APIKEY }} {{ .apikey }}", "a a"},
+		{"{{ .db.host }}", "h"},
+		{"{{ $db := .Db }}{{ $db.HOST }}", "h"},
+		{"{{ (.db).host }}", "h"},
+		{"{{ range .db.Ports }}{{ . }}{{ end }}", "12"},
+		{"{{ with .db }}{{ .HOST }}{{ end }}", "h"},
+		{`{{ index .DB "Host" }}`, "h"},
+		{"{{ .nope }}", "<no value>"},
+	}
+	for _, tt := range tests {
+		setTestOptions(t)
+		ignoreCase = true
+		got, err := renderText(tt.text, data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+		} else if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
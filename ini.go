@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeINI parses the contents of an INI file. Keys before the first section
+// are set at the top level, keys in a [section] are set under .section, and
+// dotted section names (e.g. [a.b]) create nested maps. Lines starting with ';'
+// or '#' are comments, and values may be wrapped in single or double quotes.
+func decodeINI(s string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	section := data
+	for n, l := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || l[0] == ';' || l[0] == '#' {
+			continue
+		}
+		if l[0] == '[' {
+			if l[len(l)-1] != ']' || len(l) == 2 {
+				return nil, fmt.Errorf("line %d: invalid section header %s", n+1, l)
+			}
+			var err error
+			if section, err = nestedMap(data, strings.Split(strings.TrimSpace(l[1:len(l)-1]), ".")); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			continue
+		}
+		i := strings.IndexAny(l, "=:")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n+1)
+		}
+		value := strings.TrimSpace(l[i+1:])
+		if len(value) > 1 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		section[strings.TrimSpace(l[:i])] = value
+	}
+	return data, nil
+}
+
+// decodeProperties parses the contents of a Java .properties file. Keys and
+// values are separated by '=', ':' or whitespace, lines starting with '#' or
+// '!' are comments and a trailing '\' continues the value on the next line.
+// Dotted keys (e.g. db.host) create nested maps.
+func decodeProperties(s string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for n := 0; n < len(lines); n++ {
+		start := n + 1
+		l := strings.TrimLeft(lines[n], " \t\f")
+		if l == "" || l[0] == '#' || l[0] == '!' {
+			continue
+		}
+		for continued(l) && n+1 < len(lines) {
+			n++
+			l = l[:len(l)-1] + strings.TrimLeft(lines[n], " \t\f")
+		}
+		key, value, err := splitProperty(l)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", start, err)
+		}
+		keys := strings.Split(key, ".")
+		m, err := nestedMap(data, keys[:len(keys)-1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", start, err)
+		}
+		m[keys[len(keys)-1]] = value
+	}
+	return data, nil
+}
+
+// continued reports whether l ends with an odd number of backslashes.
+func continued(l string) bool {
+	n := 0
+	for i := len(l) - 1; i >= 0 && l[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// splitProperty splits a logical .properties line into its unescaped key and
+// value.
+func splitProperty(l string) (string, string, error) {
+	var key strings.Builder
+	i := 0
+	for ; i < len(l); i++ {
+		c := l[i]
+		if c == '\\' && i+1 < len(l) {
+			i++
+			key.WriteByte(l[i])
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			break
+		}
+		key.WriteByte(c)
+	}
+	rest := strings.TrimLeft(l[i:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	value, err := unescapeProperty(rest)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid value for %s: %w", key.String(), err)
+	}
+	return key.String(), value, nil
+}
+
+func unescapeProperty(s string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("truncated \\u escape")
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid \\u escape %q", s[i-1:i+5])
+			}
+			sb.WriteRune(rune(r))
+			i += 4
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+// nestedMap returns the map at keys in data, creating intermediate maps as
+// needed. It fails if one of the keys already holds a non-map value.
+func nestedMap(data map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	m := data
+	for i, k := range keys {
+		if k == "" {
+			return nil, fmt.Errorf("empty key in %q", strings.Join(keys, "."))
+		}
+		v, ok := m[k]
+		if !ok {
+			next := make(map[string]interface{})
+			m[k] = next
+			m = next
+			continue
+		}
+		if m, ok = v.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%s is not a map", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultSecretPattern matches the keys whose values are read without echo by
+// --interactive.
+const defaultSecretPattern = `(?i)(password|passwd|secret|token|credential|private)`
+
+// promptMissing prompts on the terminal for the values of the keys referenced
+// by the input template(s) that are missing from data, and returns data with
+// the answers set as strings. Values of keys matching --interactive-secret are
+// read without echo, and empty answers leave keys missing. Nothing is prompted
+// when the standard input isn't a terminal, so strict mode fails as usual.
+func promptMissing(data interface{}) (interface{}, error) {
+	if !isTerminal(os.Stdin) {
+		return data, nil
+	}
+	secret, err := regexp.Compile(interactiveSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --interactive-secret: %w", err)
+	}
+	keys := make(map[string]bool)
+	err = forEachInput(func(name, text string) error {
+		return collectKeys(keys, name, text)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for k := range keys {
+		elems, err := parsePath(k)
+		if err != nil || len(elems) == 0 || hasWildcard(elems) || pathExists(data, elems) {
+			continue
+		}
+		missing = append(missing, k)
+	}
+	sort.Strings(missing)
+
+	in := bufio.NewReader(os.Stdin)
+	for _, k := range missing {
+		// Only leaves are prompted for, their parents are created when set
+		if isParentOfAny(k, missing) {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s: ", k)
+		var value string
+		if secret.MatchString(k) {
+			value, err = readHidden(in)
+			fmt.Fprintln(os.Stderr)
+		} else {
+			value, err = readLine(in)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", k, err)
+		}
+		if value == "" {
+			continue
+		}
+		if data, err = setValue(data, k, value); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// hasWildcard reports whether path contains a wildcard.
+func hasWildcard(path []pathElem) bool {
+	for _, e := range path {
+		if e.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// isParentOfAny reports whether the data path k is a parent of one of paths.
+func isParentOfAny(k string, paths []string) bool {
+	for _, p := range paths {
+		if strings.HasPrefix(p, k+".") || strings.HasPrefix(p, k+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// readLine reads a line from r, without its line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// listKeys returns every data path referenced by the input template(s), sorted
+// and encoded as text (one per line) or JSON.
+func listKeys() ([]byte, error) {
+	keys := make(map[string]bool)
+	err := forEachInput(func(name, text string) error {
+		return collectKeys(keys, name, text)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if scaffoldFlag {
+		return encodeData(scaffoldFormat, scaffoldData(keys))
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	if keysFormat == "json" {
+		b, err := json.MarshalIndent(sorted, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	}
+	if len(sorted) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(sorted, "\n") + "\n"), nil
+}
+
+// forEachInput calls fn with the name and contents of each input template
+// (which may be an HTTP(S) URL or given with --expr), or of every regular text
+// file not ignored by .datasubstignore when an input is a directory.
+func forEachInput(fn func(name, text string) error) error {
+	if expr != "" {
+		return fn("expr", expr)
+	}
+	for _, root := range inputNames() {
+		if err := forEachFile(root, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachFile calls fn with the name and contents of the template at root, or
+// of the templates in it if it's a directory.
+func forEachFile(root string, fn func(name, text string) error) error {
+	if root == "-" {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err == nil {
+			b, err = decodeText(b, inputEncoding)
+		}
+		if err != nil {
+			return err
+		}
+		return fn("template", string(b))
+	}
+	if isURL(root) {
+		b, err := readDataSource(root)
+		if err == nil {
+			b, err = decodeText(b, inputEncoding)
+		}
+		if err != nil {
+			return err
+		}
+		return fn(root, string(b))
+	}
+	var rules []ignoreRule
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." && info.IsDir() {
+			rules, err = readIgnoreFile(path)
+			return err
+		}
+		if ignored(rules, rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		b, err := ioutil.ReadFile(filepath.Clean(path))
+		if err != nil || isBinary(b) {
+			return err
+		}
+		if b, err = decodeInput(path, b); err != nil {
+			return err
+		}
+		return fn(path, string(b))
+	})
+}
+
+// collectKeys parses text and adds the data paths it references to keys.
+func collectKeys(keys map[string]bool, name, text string) error {
+	tpl, _, err := parseTemplateFile(name, text)
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+	if t, ok := tpl.(frontMatterTemplate); ok {
+		tpl = t.executor
+	}
+	switch t := tpl.(type) {
+	case *shellTemplate:
+		for _, ref := range t.refs {
+			keys[pathElem{key: ref.name}.String()] = true
+		}
+	case *template.Template:
+		w := &keyWalker{tpl: t, keys: keys, visiting: make(map[string]bool)}
+		w.walk(t.Tree.Root, ".", map[string]string{"$": "."})
+	}
+	return nil
+}
+
+// keyWalker walks a template's parse tree, tracking the data path of dot and
+// of variables, and records the data paths that are accessed. Paths that
+// can't be determined statically (e.g. the result of a function) are "".
+// If positions is set, the path of every data access is also recorded by its
+// position (the parse name and offset of its node).
+type keyWalker struct {
+	tpl       *template.Template
+	keys      map[string]bool
+	visiting  map[string]bool
+	positions map[string]string
+	parseName string
+}
+
+// joinPath appends suffix to the data path base.
+func joinPath(base, suffix string) string {
+	switch base {
+	case "":
+		return ""
+	case ".":
+		return suffix
+	}
+	return base + suffix
+}
+
+func (w *keyWalker) record(path string) string {
+	if path != "" && path != "." {
+		w.keys[path] = true
+	}
+	return path
+}
+
+// at records path as the data path accessed by the node at pos.
+func (w *keyWalker) at(pos parse.Pos, path string) string {
+	if w.positions != nil {
+		w.positions[nodePosition(w.parseName, pos)] = path
+	}
+	return path
+}
+
+// nodePosition identifies the node at pos in the template text named name.
+func nodePosition(name string, pos parse.Pos) string {
+	return name + ":" + strconv.Itoa(int(pos))
+}
+
+func copyVars(vars map[string]string) map[string]string {
+	c := make(map[string]string, len(vars))
+	for k, v := range vars {
+		c[k] = v
+	}
+	return c
+}
+
+func (w *keyWalker) walk(node parse.Node, dot string, vars map[string]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			w.walk(c, dot, vars)
+		}
+	case *parse.ActionNode:
+		p := w.pipe(n.Pipe, dot, vars)
+		for _, v := range n.Pipe.Decl {
+			vars[v.Ident[0]] = p
+		}
+	case *parse.IfNode:
+		w.pipe(n.Pipe, dot, vars)
+		w.walk(n.List, dot, copyVars(vars))
+		w.walk(n.ElseList, dot, copyVars(vars))
+	case *parse.WithNode:
+		p := w.pipe(n.Pipe, dot, vars)
+		inner := copyVars(vars)
+		for _, v := range n.Pipe.Decl {
+			inner[v.Ident[0]] = p
+		}
+		w.walk(n.List, p, inner)
+		w.walk(n.ElseList, dot, copyVars(vars))
+	case *parse.RangeNode:
+		p := w.pipe(n.Pipe, dot, vars)
+		elem := joinPath(p, "[]")
+		inner := copyVars(vars)
+		switch len(n.Pipe.Decl) {
+		case 1:
+			inner[n.Pipe.Decl[0].Ident[0]] = elem
+		case 2:
+			inner[n.Pipe.Decl[0].Ident[0]] = ""
+			inner[n.Pipe.Decl[1].Ident[0]] = elem
+		}
+		w.walk(n.List, elem, inner)
+		w.walk(n.ElseList, dot, copyVars(vars))
+	case *parse.TemplateNode:
+		p := ""
+		if n.Pipe != nil {
+			p = w.pipe(n.Pipe, dot, vars)
+		}
+		// Recursive templates are only walked once to guarantee termination.
+		t := w.tpl.Lookup(n.Name)
+		if t == nil || t.Tree == nil || w.visiting[n.Name] {
+			return
+		}
+		w.visiting[n.Name] = true
+		parseName := w.parseName
+		w.parseName = t.Tree.ParseName
+		w.walk(t.Tree.Root, p, map[string]string{"$": p})
+		w.parseName = parseName
+		delete(w.visiting, n.Name)
+	}
+}
+
+// pipe records the paths accessed by a pipeline and returns the path of its
+// result, if known.
+func (w *keyWalker) pipe(pipe *parse.PipeNode, dot string, vars map[string]string) string {
+	if pipe == nil {
+		return ""
+	}
+	result := ""
+	for i, cmd := range pipe.Cmds {
+		p := w.command(cmd, dot, vars)
+		if i == 0 {
+			result = p
+		} else {
+			result = ""
+		}
+	}
+	return result
+}
+
+func (w *keyWalker) command(cmd *parse.CommandNode, dot string, vars map[string]string) string {
+	paths := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		paths[i] = w.arg(arg, dot, vars)
+	}
+	if len(cmd.Args) == 1 {
+		return paths[0]
+	}
+	// Maps ranged over in the order of their keys
+	if id, ok := cmd.Args[0].(*parse.IdentifierNode); ok && id.Ident == rangeValuesFuncName {
+		return paths[1]
+	}
+	// index with literal keys, e.g. (index .key2 "first"), is a data access,
+	// like the field accesses rewritten with --ignore-case.
+	if id, ok := cmd.Args[0].(*parse.IdentifierNode); ok && (id.Ident == "index" || id.Ident == ignoreCaseFuncName) && paths[1] != "" {
+		p := paths[1]
+		for _, arg := range cmd.Args[2:] {
+			switch a := arg.(type) {
+			case *parse.StringNode:
+				p = joinPath(p, pathElem{key: a.Text}.String())
+			case *parse.NumberNode:
+				if !a.IsInt {
+					return ""
+				}
+				p = joinPath(p, "["+strconv.FormatInt(a.Int64, 10)+"]")
+			default:
+				return ""
+			}
+		}
+		return w.at(cmd.Position(), w.record(p))
+	}
+	return ""
+}
+
+func (w *keyWalker) arg(node parse.Node, dot string, vars map[string]string) string {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return w.at(n.Position(), w.record(joinPath(dot, "."+strings.Join(n.Ident, "."))))
+	case *parse.VariableNode:
+		p := vars[n.Ident[0]]
+		if len(n.Ident) == 1 {
+			return p
+		}
+		return w.at(n.Position(), w.record(joinPath(p, "."+strings.Join(n.Ident[1:], "."))))
+	case *parse.ChainNode:
+		p := w.arg(n.Node, dot, vars)
+		return w.at(n.Position(), w.record(joinPath(p, "."+strings.Join(n.Field, "."))))
+	case *parse.PipeNode:
+		return w.pipe(n, dot, vars)
+	case *parse.DotNode:
+		return dot
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// renderResourceList implements the KRM function specification used by
+// kustomize and kpt: it reads a ResourceList from the standard input, renders
+// the string values of every resource in its items as templates and returns
+// the resulting ResourceList.
+//
+// The function config provides additional data, merged over the data sources:
+// the data of a ConfigMap or, for any other kind, spec.data. Its spec.template
+// (if any) is a generator: it is rendered and the resources it contains are
+// appended to the items.
+func renderResourceList(data interface{}) ([]byte, error) {
+	b, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("reading resource list: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parsing resource list: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parsing resource list: expected a ResourceList")
+	}
+	list := doc.Content[0]
+	if kind := mappingValue(list, "kind"); kind == nil || kind.Value != "ResourceList" {
+		return nil, fmt.Errorf("parsing resource list: expected kind ResourceList")
+	}
+	items := mappingValue(list, "items")
+	if items == nil {
+		items = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "items"}, items)
+	}
+
+	var generator string
+	if config := mappingValue(list, "functionConfig"); config != nil {
+		var fc struct {
+			Kind string                 `yaml:"kind"`
+			Data map[string]interface{} `yaml:"data"`
+			Spec struct {
+				Data     map[string]interface{} `yaml:"data"`
+				Template string                 `yaml:"template"`
+			} `yaml:"spec"`
+		}
+		if err := config.Decode(&fc); err != nil {
+			return nil, fmt.Errorf("parsing function config: %w", err)
+		}
+		configData := fc.Spec.Data
+		if fc.Kind == "ConfigMap" {
+			configData = fc.Data
+		}
+		if configData != nil {
+			var err error
+			if data, err = mergeData(data, normalizeData(configData), mergeStrategy == "deep"); err != nil {
+				return nil, fmt.Errorf("merging function config data: %w", err)
+			}
+		}
+		generator = fc.Spec.Template
+	}
+
+	for i, item := range items.Content {
+		if err := renderNode(item, data); err != nil {
+			return nil, fmt.Errorf("rendering item %d: %w", i, err)
+		}
+	}
+	if generator != "" {
+		generated, err := renderGenerator(generator, data)
+		if err != nil {
+			return nil, fmt.Errorf("rendering spec.template: %w", err)
+		}
+		items.Content = append(items.Content, generated...)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mappingValue returns the value of key in the mapping node m, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// renderNode renders, in place, the string scalars in node that contain
+// template actions.
+func renderNode(node *yaml.Node, data interface{}) error {
+	if node.Kind == yaml.ScalarNode {
+		if node.Tag != "!!str" || !isTemplateText(node.Value) {
+			return nil
+		}
+		tpl, err := parseTemplate("value", node.Value)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, data); err != nil {
+			return err
+		}
+		node.Value = buf.String()
+		return nil
+	}
+	for _, c := range node.Content {
+		if err := renderNode(c, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTemplateText reports whether s may contain template actions or references.
+func isTemplateText(s string) bool {
+	if shellFormat {
+		return strings.Contains(s, "$")
+	}
+	left := leftDelim
+	if left == "" {
+		left = "{{"
+	}
+	return strings.Contains(s, left)
+}
+
+// renderGenerator renders the generator template text and returns the
+// resources it contains.
+func renderGenerator(text string, data interface{}) ([]*yaml.Node, error) {
+	tpl, err := parseTemplate("spec.template", text)
+	if err != nil {
+		return nil, err
+	}
+	b, err := execute(tpl, data)
+	if err != nil {
+		return nil, err
+	}
+	var resources []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.MappingNode {
+			resources = append(resources, doc.Content[0])
+		}
+	}
+	return resources, nil
+}
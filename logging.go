@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// logLevels are the levels of --log-level, from the most verbose.
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// logPrefixes are the prefixes of the log messages of each level. Other
+// messages are logged at the info level.
+var logPrefixes = []struct{ prefix, level string }{
+	{"Error: ", "error"},
+	{"Error ", "error"},
+	{"Warning: ", "warn"},
+	{"Trace: ", "debug"},
+}
+
+// logEntry is a log message written with --log-format json.
+type logEntry struct {
+	Time  string `json:"time"`
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+}
+
+// logWriter is the log output, dropping the messages below --log-level and
+// writing the others, with the values of --mask-keys redacted, as JSON objects
+// (one per line) with --log-format json.
+type logWriter struct {
+	out      io.Writer
+	minLevel int
+	json     bool
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	level, msg := parseLogMessage(string(p))
+	if levelIndex(level) < w.minLevel {
+		return len(p), nil
+	}
+	msg = maskSecrets(msg)
+	if !w.json {
+		if _, err := io.WriteString(w.out, maskSecrets(string(p))); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(logEntry{
+		Time:  time.Now().UTC().Format(time.RFC3339Nano),
+		Level: level,
+		Msg:   msg,
+	}); err != nil {
+		return 0, err
+	}
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// parseLogMessage returns the level of the log message s and s without its
+// level prefix and trailing newline.
+func parseLogMessage(s string) (string, string) {
+	s = strings.TrimRight(s, "\n")
+	for _, p := range logPrefixes {
+		if strings.HasPrefix(s, p.prefix) {
+			return p.level, strings.TrimPrefix(s, p.prefix)
+		}
+	}
+	return "info", s
+}
+
+// levelIndex returns the index of level in logLevels, or -1 if it's invalid.
+func levelIndex(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// setupLogging sets the log output according to --log-format and --log-level
+// (debug with --trace, info otherwise, if not set), adding the warnings to the
+// summary with --report.
+func setupLogging() error {
+	if logFormat != "text" && logFormat != "json" {
+		return fmt.Errorf("invalid log format %q, must be 'text' or 'json'", logFormat)
+	}
+	level := logLevel
+	if level == "" {
+		level = "info"
+		if traceFlag {
+			level = "debug"
+		}
+	}
+	minLevel := levelIndex(level)
+	if minLevel < 0 {
+		return fmt.Errorf("invalid log level %q, must be one of %s", level, strings.Join(logLevels, ", "))
+	}
+	var out io.Writer = logWriter{out: os.Stderr, minLevel: minLevel, json: logFormat == "json"}
+	if reportFile != "" {
+		out = warningWriter{out: out}
+	}
+	log.SetOutput(out)
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+// setTestOptions resets the global options used to parse and render templates
+// to their defaults for the duration of the test.
+func setTestOptions(t *testing.T) {
+	t.Helper()
+	saved := struct {
+		missingKey, missingPlaceholder, mapOrder string
+		ignoreCase, traceFlag                    bool
+	}{missingKey, missingPlaceholder, mapOrder, ignoreCase, traceFlag}
+	missingKey, missingPlaceholder, mapOrder = "default", defaultMissingPlaceholder, "sorted"
+	ignoreCase, traceFlag = false, false
+	t.Cleanup(func() {
+		missingKey, missingPlaceholder, mapOrder = saved.missingKey, saved.missingPlaceholder, saved.mapOrder
+		ignoreCase, traceFlag = saved.ignoreCase, saved.traceFlag
+	})
+}
+
+// renderText parses text as a template with the global options and renders it
+// against data.
+func renderText(text string, data interface{}) (string, error) {
+	tpl, err := newTemplate("test", text)
+	if err != nil {
+		return "", err
+	}
+	b, err := execute(tpl, data)
+	return string(b), err
+}
+
+// yamlData decodes the YAML document s, recording its key order with
+// --map-order source.
+func yamlData(t *testing.T, s string) interface{} {
+	t.Helper()
+	data, err := decodeData("yaml", []byte(s))
+	if err != nil {
+		t.Fatalf("decoding %q: %v", s, err)
+	}
+	return data
+}
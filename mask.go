@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// maskKeyPattern matches the names of the keys set by --mask-keys, or is
+	// nil if not set.
+	maskKeyPattern *regexp.Regexp
+
+	// secretMasker redacts the secretValues, the values of the keys matching
+	// maskKeyPattern in the data being rendered, guarded by maskMu as logs are
+	// written concurrently.
+	secretMasker *strings.Replacer
+	secretValues map[string]bool
+	maskMu       sync.Mutex
+)
+
+// minSecretLength is the minimum length of the values redacted by --mask-keys:
+// shorter ones (e.g. 1 or yes) would redact unrelated text.
+const minSecretLength = 4
+
+// parseMaskKeys parses the comma-separated list of --mask-keys regexps, each
+// matching whole key names regardless of case (e.g. 'password,.*_secret').
+func parseMaskKeys(s string) (*regexp.Regexp, error) {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("invalid --mask-keys pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, "(?:"+p+")")
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile("^(?i:" + strings.Join(patterns, "|") + ")$")
+}
+
+// isMaskedKey reports whether the key name matches --mask-keys.
+func isMaskedKey(name string) bool {
+	return maskKeyPattern != nil && maskKeyPattern.MatchString(name)
+}
+
+// isMaskedExpr reports whether a field or key referenced by the template
+// expression expr (e.g. .db.password) matches --mask-keys.
+func isMaskedExpr(expr string) bool {
+	if maskKeyPattern == nil {
+		return false
+	}
+	for _, name := range strings.FieldsFunc(expr, isNotKeyChar) {
+		if isMaskedKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotKeyChar reports whether r can't be part of a key name in a template
+// expression.
+func isNotKeyChar(r rune) bool {
+	return !(r == '_' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f)
+}
+
+// setSecrets collects the values of the keys of data matching --mask-keys
+// (including all the values nested under them), which are then redacted from
+// the logs, errors and --report, replacing those of the previous data.
+func setSecrets(data interface{}) {
+	maskMu.Lock()
+	secretValues = nil
+	secretMasker = nil
+	maskMu.Unlock()
+	addSecrets(data)
+}
+
+// addSecrets adds the values of the keys of data matching --mask-keys to the
+// redacted ones, e.g. for the per-template data of pair files and front
+// matter.
+func addSecrets(data interface{}) {
+	if maskKeyPattern == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	collectSecrets(data, false, seen)
+	maskMu.Lock()
+	defer maskMu.Unlock()
+	added := false
+	for s := range seen {
+		if !secretValues[s] {
+			if secretValues == nil {
+				secretValues = make(map[string]bool)
+			}
+			secretValues[s] = true
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+	secrets := make([]string, 0, len(secretValues))
+	for s := range secretValues {
+		secrets = append(secrets, s)
+	}
+	// Replace the longest values first, as they may contain shorter ones
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return secrets[i] < secrets[j]
+	})
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		pairs = append(pairs, s, maskedValue)
+	}
+	secretMasker = strings.NewReplacer(pairs...)
+}
+
+func collectSecrets(v interface{}, masked bool, seen map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			collectSecrets(e, masked || isMaskedKey(k), seen)
+		}
+	case []interface{}:
+		for _, e := range v {
+			collectSecrets(e, masked, seen)
+		}
+	case nil, bool:
+	default:
+		if s := fmt.Sprint(v); masked && len(s) >= minSecretLength {
+			seen[s] = true
+		}
+	}
+}
+
+// maskSecrets redacts the values of the keys matching --mask-keys from s.
+func maskSecrets(s string) string {
+	maskMu.Lock()
+	defer maskMu.Unlock()
+	if secretMasker == nil {
+		return s
+	}
+	return secretMasker.Replace(s)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"text/template"
+	"text/template/parse"
+)
+
+// missingFuncName is the function appended to printed pipelines to apply the
+// --missing-key policy.
+const missingFuncName = "_missing"
+
+// defaultMissingPlaceholder is what go templates print for missing values.
+const defaultMissingPlaceholder = "<no value>"
+
+// rewriteMissing reports whether templates need to be rewritten with
+// missingTemplate to apply the missing key policy.
+func rewriteMissing(policy string) bool {
+	return policy != "default" || missingPlaceholder != defaultMissingPlaceholder
+}
+
+// missingFuncs returns the functions needed by templates rewritten with
+// missingTemplate to apply the missing key policy.
+func missingFuncs(policy string) template.FuncMap {
+	return template.FuncMap{
+		missingFuncName: func(location, expr string, v interface{}) (interface{}, error) {
+			if v != nil {
+				return v, nil
+			}
+			switch policy {
+			case "error":
+				return nil, fmt.Errorf("%s has no value", expr)
+			case "warn":
+				log.Printf("Warning: %s: %s has no value\n", location, expr)
+				return "", nil
+			case "zero":
+				return "", nil
+			}
+			return missingPlaceholder, nil
+		},
+	}
+}
+
+// missingTemplate rewrites every printed action in tpl so that missing keys
+// and null values (which would otherwise render as "<no value>") are handled
+// according to the --missing-key policy, naming the offending expression.
+func missingTemplate(tpl *template.Template) {
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil && t.Tree.Root != nil {
+			missingNode(t.Tree, t.Tree.Root)
+		}
+	}
+}
+
+func missingNode(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			missingNode(tree, c)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 || n.Pipe.IsAssign {
+			return
+		}
+		location, _ := tree.ErrorContext(n)
+		expr := n.Pipe.String()
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args: []parse.Node{
+				parse.NewIdentifier(missingFuncName).SetTree(tree).SetPos(n.Pos),
+				&parse.StringNode{NodeType: parse.NodeString, Pos: n.Pos, Quoted: strconv.Quote(location), Text: location},
+				&parse.StringNode{NodeType: parse.NodeString, Pos: n.Pos, Quoted: strconv.Quote(expr), Text: expr},
+			},
+		})
+	case *parse.IfNode:
+		missingNode(tree, n.List)
+		missingNode(tree, n.ElseList)
+	case *parse.RangeNode:
+		missingNode(tree, n.List)
+		missingNode(tree, n.ElseList)
+	case *parse.WithNode:
+		missingNode(tree, n.List)
+		missingNode(tree, n.ElseList)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMissingKey(t *testing.T) {
+	data := map[string]interface{}{"a": "x", "null": nil, "m": map[string]interface{}{"b": "y"}}
+	tests := []struct {
+		policy, placeholder string
+		text                string
+		want, err           string
+	}{
+		{"default", defaultMissingPlaceholder, "{{ .a }}-{{ .nope }}", "x-<no value>", ""},
+		{"default", "???", "{{ .a }}-{{ .nope }}-{{ .null }}", "x-???-???", ""},
+		{"zero", defaultMissingPlaceholder, "{{ .a }}-{{ .nope }}-{{ .m.nope }}", "x--", ""},
+		{"warn", defaultMissingPlaceholder, "{{ .a }}-{{ .null }}", "x-", ""},
+		{"error", defaultMissingPlaceholder, "{{ .a }}-{{ .null }}", "", ".null has no value"},
+		{"error", defaultMissingPlaceholder, "{{ .nope }}", "", `no entry for key "nope"`},
+		{"error", defaultMissingPlaceholder, "{{ .null | default \"z\" }}", "z", ""},
+		{"zero", defaultMissingPlaceholder, "{{ $v := .nope }}{{ if .nope }}yes{{ else }}{{ .m.b }}{{ end }}", "y", ""},
+		{"zero", defaultMissingPlaceholder, "{{ range .m }}{{ . }}{{ .nope }}{{ end }}", "", "can't evaluate field nope"},
+		{"zero", defaultMissingPlaceholder, "{{ with .m }}{{ .b }}{{ .nope }}{{ end }}", "y", ""},
+	}
+	for _, tt := range tests {
+		setTestOptions(t)
+		missingKey, missingPlaceholder = tt.policy, tt.placeholder
+		got, err := renderText(tt.text, data)
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s with --missing-key %s: got error %v, want %s", tt.text, tt.policy, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("%s with --missing-key %s: %v", tt.text, tt.policy, err)
+		case tt.err == "" && got != tt.want:
+			t.Errorf("%s with --missing-key %s = %q, want %q", tt.text, tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestMissingKeyWarning(t *testing.T) {
+	setTestOptions(t)
+	missingKey = "warn"
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	if _, err := renderText("a\n{{ .m.nope }}", map[string]interface{}{"m": map[string]interface{}{}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Warning: test:2:3: .m.nope has no value"; !strings.Contains(logs.String(), want) {
+		t.Errorf("logs %q don't contain %q", logs.String(), want)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"runtime"
+)
+
+// newlineStyle returns the line endings rendered outputs are normalized to:
+// those set by --newline ("native" being those of the platform), else "crlf"
+// for a template whose text uses them (e.g. a Windows .bat file rendered in
+// directory mode), so its endings are preserved. Outputs are left untouched
+// for an empty style.
+func newlineStyle(text []byte) string {
+	switch newline {
+	case "native":
+		if runtime.GOOS == "windows" {
+			return "crlf"
+		}
+		return "lf"
+	case "":
+		if bytes.Contains(text, []byte("\r\n")) {
+			return "crlf"
+		}
+		return ""
+	}
+	return newline
+}
+
+// convertNewlines normalizes the line endings of b to style (lf or crlf).
+func convertNewlines(b []byte, style string) []byte {
+	switch style {
+	case "lf":
+		return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	case "crlf":
+		b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+		return bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n"))
+	}
+	return b
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The data decoded from YAML and JSON sources are plain maps, which go
+// templates and encoders iterate in sorted key order. Their key order in the
+// source is recorded in keyOrders (kept up to date as data sources are merged)
+// with --map-order source, so that range and toYaml/toJson follow it.
+
+// keyOrder is the order of the keys of a map.
+type keyOrder struct {
+	// m keeps the map alive so its address isn't reused by another map
+	m    map[string]interface{}
+	keys []string
+}
+
+var (
+	// keyOrders maps the address of maps to their *keyOrder.
+	keyOrders   sync.Map
+	keyOrdersMu sync.Mutex
+)
+
+// sourceOrder reports whether maps keep the key order of their source.
+func sourceOrder() bool {
+	return mapOrder == "source"
+}
+
+func mapID(m map[string]interface{}) uintptr {
+	return reflect.ValueOf(m).Pointer()
+}
+
+// resetKeyOrders forgets the key orders of the previously loaded data.
+func resetKeyOrders() {
+	keyOrders.Range(func(k, _ interface{}) bool {
+		keyOrders.Delete(k)
+		return true
+	})
+}
+
+// setKeyOrder records keys as the order of the keys of m, with --map-order
+// source.
+func setKeyOrder(m map[string]interface{}, keys []string) {
+	if m == nil || !sourceOrder() {
+		return
+	}
+	keyOrders.Store(mapID(m), &keyOrder{m: m, keys: keys})
+}
+
+// recordedKeys returns the recorded order of the keys of m, if any.
+func recordedKeys(m map[string]interface{}) ([]string, bool) {
+	if m == nil {
+		return nil, false
+	}
+	v, ok := keyOrders.Load(mapID(m))
+	if !ok {
+		return nil, false
+	}
+	keyOrdersMu.Lock()
+	defer keyOrdersMu.Unlock()
+	return v.(*keyOrder).keys, true
+}
+
+// appendKeyOrder records key, added to m, as its last key if the order of m
+// is recorded.
+func appendKeyOrder(m map[string]interface{}, key string) {
+	if m == nil {
+		return
+	}
+	if v, ok := keyOrders.Load(mapID(m)); ok {
+		keyOrdersMu.Lock()
+		defer keyOrdersMu.Unlock()
+		o := v.(*keyOrder)
+		o.keys = append(o.keys[:len(o.keys):len(o.keys)], key)
+	}
+}
+
+// removeKeyOrder forgets key, deleted from m.
+func removeKeyOrder(m map[string]interface{}, key string) {
+	if m == nil {
+		return
+	}
+	if v, ok := keyOrders.Load(mapID(m)); ok {
+		keyOrdersMu.Lock()
+		defer keyOrdersMu.Unlock()
+		o := v.(*keyOrder)
+		keys := make([]string, 0, len(o.keys))
+		for _, k := range o.keys {
+			if k != key {
+				keys = append(keys, k)
+			}
+		}
+		o.keys = keys
+	}
+}
+
+// orderedKeys returns the keys of m in their recorded order, followed by the
+// keys missing from it in sorted order. Keys are sorted if no order is
+// recorded or with --map-order sorted.
+func orderedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	seen := make(map[string]bool, len(m))
+	if recorded, ok := recordedKeys(m); ok && sourceOrder() {
+		for _, k := range recorded {
+			if _, ok := m[k]; ok && !seen[k] {
+				keys = append(keys, k)
+				seen[k] = true
+			}
+		}
+		if len(keys) == len(m) {
+			return keys
+		}
+	}
+	rest := make([]string, 0, len(m)-len(keys))
+	for k := range m {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// recordYAMLOrder records the key order of the maps of v, decoded from node.
+func recordYAMLOrder(node *yaml.Node, v interface{}) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			recordYAMLOrder(node.Content[0], v)
+		}
+	case yaml.AliasNode:
+		if node.Alias != nil {
+			recordYAMLOrder(node.Alias, v)
+		}
+	case yaml.MappingNode:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		keys := make([]string, 0, len(m))
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k := node.Content[i].Value
+			if _, ok := m[k]; !ok || node.Content[i].Tag == "!!merge" {
+				continue
+			}
+			keys = append(keys, k)
+			recordYAMLOrder(node.Content[i+1], m[k])
+		}
+		setKeyOrder(m, keys)
+	case yaml.SequenceNode:
+		l, ok := v.([]interface{})
+		if !ok {
+			return
+		}
+		for i, n := range node.Content {
+			if i < len(l) {
+				recordYAMLOrder(n, l[i])
+			}
+		}
+	}
+}
+
+// recordJSONOrder records the key order of the maps of v, decoded from the
+// JSON document b, which is also a YAML document. Nothing is recorded if it
+// can't be parsed as such (e.g. JSON with comments).
+func recordJSONOrder(b []byte, v interface{}) {
+	var node yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(b)).Decode(&node); err != nil && err != io.EOF {
+		return
+	}
+	recordYAMLOrder(&node, v)
+}
+
+// orderedMap is a map encoded in the order of its keys by toJson and toYaml.
+type orderedMap struct {
+	keys []string
+	m    map[string]interface{}
+}
+
+func (o orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(o.m[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (o orderedMap) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, k := range o.keys {
+		var key, value yaml.Node
+		if err := key.Encode(k); err != nil {
+			return nil, err
+		}
+		if err := value.Encode(o.m[k]); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &key, &value)
+	}
+	return node, nil
+}
+
+// withKeyOrder returns v with the maps whose order is recorded replaced with
+// orderedMaps, to encode them in that order.
+func withKeyOrder(v interface{}) interface{} {
+	if !sourceOrder() {
+		return v
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := orderedKeys(v)
+		m := make(map[string]interface{}, len(v))
+		for _, k := range keys {
+			m[k] = withKeyOrder(v[k])
+		}
+		if _, ok := recordedKeys(v); !ok {
+			return m
+		}
+		return orderedMap{keys: keys, m: m}
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = withKeyOrder(e)
+		}
+		return l
+	}
+	return v
+}
+
+// rangeValuesFuncName and rangeKeyFuncName are the functions the range
+// actions of templates are rewritten to call to iterate over maps in the
+// order of their keys.
+const (
+	rangeValuesFuncName = "_rangeValues"
+	rangeKeyFuncName    = "_rangeKey"
+)
+
+// mapOrderFuncs returns the functions needed by templates rewritten with
+// mapOrderTemplate.
+func mapOrderFuncs() template.FuncMap {
+	return template.FuncMap{
+		// Maps are ranged over as the list of their values, in order
+		rangeValuesFuncName: func(v interface{}) interface{} {
+			m, ok := v.(map[string]interface{})
+			if !ok || len(m) == 0 {
+				return v
+			}
+			keys := orderedKeys(m)
+			values := make([]interface{}, len(keys))
+			for i, k := range keys {
+				values[i] = m[k]
+			}
+			return values
+		},
+		// ... and the key of the value at index i is looked up
+		rangeKeyFuncName: func(v interface{}, i interface{}) interface{} {
+			m, ok := v.(map[string]interface{})
+			n, isInt := i.(int)
+			if !ok || !isInt {
+				return i
+			}
+			if keys := orderedKeys(m); n >= 0 && n < len(keys) {
+				return keys[n]
+			}
+			return i
+		},
+	}
+}
+
+// mapOrderTemplate rewrites the range actions of tpl to iterate over maps in
+// the order of their keys. As range always iterates over maps in sorted key
+// order,
+//
+//	{{ range $k, $v := PIPELINE }}...{{ end }}
+//
+// is rewritten to range over the list of the values of the map instead:
+//
+//	{{ $_range1 := PIPELINE }}{{ range $k, $v := _rangeValues $_range1 }}{{ $k = _rangeKey $_range1 $k }}...{{ end }}
+func mapOrderTemplate(tpl *template.Template) {
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil && t.Tree.Root != nil {
+			n := 0
+			mapOrderNode(t.Tree, t.Tree.Root, &n)
+		}
+	}
+}
+
+func mapOrderNode(tree *parse.Tree, node parse.Node, n *int) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		nodes := make([]parse.Node, 0, len(node.Nodes))
+		for _, c := range node.Nodes {
+			if r, ok := c.(*parse.RangeNode); ok {
+				*n++
+				nodes = append(nodes, rangeValues(tree, r, "$_range"+strconv.Itoa(*n)))
+			}
+			nodes = append(nodes, c)
+			mapOrderNode(tree, c, n)
+		}
+		node.Nodes = nodes
+	case *parse.IfNode:
+		mapOrderNode(tree, node.List, n)
+		mapOrderNode(tree, node.ElseList, n)
+	case *parse.RangeNode:
+		mapOrderNode(tree, node.List, n)
+		mapOrderNode(tree, node.ElseList, n)
+	case *parse.WithNode:
+		mapOrderNode(tree, node.List, n)
+		mapOrderNode(tree, node.ElseList, n)
+	}
+}
+
+// rangeValues rewrites r to range over the values of the result of its
+// pipeline, which is assigned to the variable name by the returned action.
+func rangeValues(tree *parse.Tree, r *parse.RangeNode, name string) parse.Node {
+	pos := r.Pos
+	variable := func() *parse.VariableNode {
+		return &parse.VariableNode{NodeType: parse.NodeVariable, Pos: pos, Ident: []string{name}}
+	}
+	call := func(fn string, args ...parse.Node) []*parse.CommandNode {
+		args = append([]parse.Node{parse.NewIdentifier(fn).SetTree(tree).SetPos(pos)}, args...)
+		return []*parse.CommandNode{{NodeType: parse.NodeCommand, Pos: pos, Args: args}}
+	}
+	decl := &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pos:      pos,
+		Line:     r.Line,
+		Pipe:     &parse.PipeNode{NodeType: parse.NodePipe, Pos: pos, Line: r.Line, Decl: []*parse.VariableNode{variable()}, Cmds: r.Pipe.Cmds},
+	}
+	r.Pipe.Cmds = call(rangeValuesFuncName, variable())
+	if len(r.Pipe.Decl) == 2 && r.List != nil {
+		key := r.Pipe.Decl[0]
+		assign := &parse.ActionNode{
+			NodeType: parse.NodeAction,
+			Pos:      pos,
+			Line:     r.Line,
+			Pipe: &parse.PipeNode{
+				NodeType: parse.NodePipe,
+				Pos:      pos,
+				Line:     r.Line,
+				IsAssign: true,
+				Decl:     []*parse.VariableNode{{NodeType: parse.NodeVariable, Pos: pos, Ident: key.Ident}},
+				Cmds:     call(rangeKeyFuncName, variable(), &parse.VariableNode{NodeType: parse.NodeVariable, Pos: pos, Ident: key.Ident}),
+			},
+		}
+		r.List.Nodes = append([]parse.Node{assign}, r.List.Nodes...)
+	}
+	return decl
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMapOrder(t *testing.T) {
+	const data = "m:\n  b: {x: 1}\n  a: {}\n  c: {x: 3}\nl: [z, y]\nempty: {}\n"
+	tests := []struct {
+		name       string
+		mapOrder   string
+		ignoreCase bool
+		missingKey string
+		text       string
+		want       string
+	}{
+		{"sorted by default", "sorted", false, "default", `{{ range $k, $v := .m }}{{ $k }}{{ end }}`, "abc"},
+		{"source keys", "source", false, "default", `{{ range $k, $v := .m }}{{ $k }}{{ end }}`, "bac"},
+		{"source values", "source", false, "default", `{{ range .m }}{{ .x }};{{ end }}`, "1;<no value>;3;"},
+		{"source value variable", "source", false, "default", `{{ range $v := .m }}{{ $v.x }};{{ end }}`, "1;<no value>;3;"},
+		{"source nested", "source", false, "default", `{{ range $k, $v := .m }}{{ range $k2, $v2 := $v }}{{ $k }}.{{ $k2 }}={{ $v2 }} {{ end }}{{ end }}`, "b.x=1 c.x=3 "},
+		{"source else", "source", false, "default", `{{ range .empty }}x{{ else }}none{{ end }}`, "none"},
+		{"source list", "source", false, "default", `{{ range $i, $v := .l }}{{ $i }}{{ $v }}{{ end }}`, "0z1y"},
+		{"source ignore case", "source", true, "default", `{{ range $k, $v := .M }}{{ $k }}{{ $v.X }};{{ end }}`, "b1;a<no value>;c3;"},
+		{"source missing zero", "source", false, "zero", `{{ range .m }}{{ .x }};{{ end }}`, "1;;3;"},
+		{"source missing placeholder", "source", false, "default", `{{ range $k, $v := .m }}{{ $k }}{{ .nope }}{{ end }}`, "b<no value>a<no value>c<no value>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestOptions(t)
+			mapOrder, ignoreCase, missingKey = tt.mapOrder, tt.ignoreCase, tt.missingKey
+			got, err := renderText(tt.text, yamlData(t, data))
+			if err != nil {
+				t.Fatalf("rendering %s: %v", tt.text, err)
+			}
+			if got != tt.want {
+				t.Errorf("rendering %s = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapOrderMissingKeyError(t *testing.T) {
+	setTestOptions(t)
+	mapOrder, missingKey = "source", "error"
+	_, err := renderText(`{{ range $k, $v := .m }}{{ $v.nope }}{{ end }}`, yamlData(t, "m: {b: {}, a: {}}"))
+	if err == nil || !strings.Contains(err.Error(), `no entry for key "nope"`) {
+		t.Errorf("got error %v, want no entry for key \"nope\"", err)
+	}
+}
+
+func TestMapOrderTrace(t *testing.T) {
+	setTestOptions(t)
+	mapOrder, traceFlag = "source", true
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	got, err := renderText(`{{ range $k, $v := .m }}{{ $k }}={{ $v }} {{ end }}`, yamlData(t, "m: {b: 1, a: 2}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "b=1 a=2 "; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// The range is traced as written, not as rewritten
+	for _, want := range []string{"{{ $k, $v := .m }} = {\"b\":1,\"a\":2}", "{{ $k }} = \"b\"", "{{ $v }} = 1"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("trace %q doesn't contain %q", logs.String(), want)
+		}
+	}
+	if strings.Contains(logs.String(), rangeValuesFuncName) {
+		t.Errorf("trace %q contains the rewritten range", logs.String())
+	}
+}
+
+func TestOrderedKeys(t *testing.T) {
+	tests := []struct {
+		mapOrder string
+		format   string
+		data     string
+		merged   string
+		want     string
+	}{
+		{"source", "yaml", "b: 1\na: 2\nc: 3", "", "b a c"},
+		{"source", "json", `{"b": 1, "a": 2, "c": 3}`, "", "b a c"},
+		{"sorted", "yaml", "b: 1\na: 2\nc: 3", "", "a b c"},
+		{"source", "yaml", "b: 1\na: 2", "z: 1\nc: 2\na: 3", "b a z c"},
+		{"sorted", "yaml", "b: 1\na: 2", "z: 1\nc: 2", "a b c z"},
+	}
+	for _, tt := range tests {
+		setTestOptions(t)
+		mapOrder = tt.mapOrder
+		data, err := decodeData(tt.format, []byte(tt.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.merged != "" {
+			if data, err = mergeData(data, yamlData(t, tt.merged), true); err != nil {
+				t.Fatal(err)
+			}
+		}
+		m := data.(map[string]interface{})
+		if got := strings.Join(orderedKeys(m), " "); got != tt.want {
+			t.Errorf("keys of %q merged with %q with --map-order %s = %s, want %s", tt.data, tt.merged, tt.mapOrder, got, tt.want)
+		}
+		got, err := renderText(`{{ range $k, $v := . }}{{ $k }} {{ end }}`, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want+" " {
+			t.Errorf("ranging over %q merged with %q with --map-order %s = %s, want %s", tt.data, tt.merged, tt.mapOrder, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// outdated is set when --diff or --dry-run find an output that would change.
+var outdated bool
+
+// compareMu serializes the reporting of outdated outputs, which may be compared
+// concurrently with --workers.
+var compareMu sync.Mutex
+
+// dryRunMode reports whether outputs should be compared (or, with the validate
+// command, discarded) rather than written.
+func dryRunMode() bool {
+	return diffFlag || dryRunFlag || validateOnly
+}
+
+// writeOutput writes the rendered content b to the file at dst, creating
+// parent directories as needed. A mode of 0 creates the file with the default
+// permissions (0666 before umask) and leaves existing files' modes unchanged.
+// --chmod overrides mode, and --chown sets the owner of the file.
+// Unless --no-atomic is set, b is written to a temporary file renamed to dst,
+// so readers never see a partially written file. With --idempotent, dst is
+// left untouched (preserving its mtime) if it already contains b. With --diff
+// or --dry-run, dst is compared against b instead, and nothing is written by
+// the validate command. With --reproducible, the modification time of dst is
+// set to SOURCE_DATE_EPOCH.
+func writeOutput(dst string, mode os.FileMode, b []byte) error {
+	if validateOnly {
+		return nil
+	}
+	if dryRunMode() {
+		return compareOutput(dst, b)
+	}
+	if outputMode != 0 {
+		mode = outputMode
+	}
+	if idempotent {
+		if current, err := ioutil.ReadFile(filepath.Clean(dst)); err == nil && bytes.Equal(current, b) {
+			if mode != 0 {
+				if err := os.Chmod(dst, mode); err != nil {
+					return err
+				}
+			}
+			if chownUID >= 0 || chownGID >= 0 {
+				return os.Chown(dst, chownUID, chownGID)
+			}
+			return nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if noAtomic {
+		out, err := os.OpenFile(filepath.Clean(dst), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, createMode(mode))
+		if err != nil {
+			return err
+		}
+		if err := writeFile(out, mode, b); err != nil {
+			return err
+		}
+		return setOutputTime(dst)
+	}
+
+	// Write through symlinks rather than replacing them
+	if target, err := filepath.EvalSymlinks(dst); err == nil {
+		dst = target
+	}
+	if mode == 0 {
+		if info, err := os.Stat(dst); err == nil {
+			mode = info.Mode().Perm()
+		}
+	}
+	out, err := createTemp(dst, createMode(mode))
+	if err != nil {
+		return err
+	}
+	if err := writeFile(out, mode, b); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	if err := os.Rename(out.Name(), dst); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return setOutputTime(dst)
+}
+
+// createMode returns the permissions to create an output file with mode: the
+// default ones (0666 before umask) if mode is 0, or 0600 otherwise, so the file
+// is never more accessible than mode until writeFile sets it.
+func createMode(mode os.FileMode) os.FileMode {
+	if mode == 0 {
+		return 0666
+	}
+	return 0600
+}
+
+// writeFile sets the mode of out unless it's 0 and its owner with --chown, then
+// writes b to it and closes it. The mode and owner are set first, so the
+// content is never readable with the permissions of the file before.
+func writeFile(out *os.File, mode os.FileMode, b []byte) error {
+	if chownUID >= 0 || chownGID >= 0 {
+		if err := out.Chown(chownUID, chownGID); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	// The mode passed to OpenFile is subject to umask and ignored for existing
+	// files, so set it explicitly.
+	if mode != 0 {
+		if err := out.Chmod(mode); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if _, err := out.Write(b); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// tempSuffix is the suffix of the temporary files created by createTemp.
+const tempSuffix = ".datasubst.tmp"
+
+// isTempFile reports whether path is a temporary file created by createTemp.
+func isTempFile(path string) bool {
+	base := filepath.Base(path)
+	return strings.HasPrefix(base, ".") && strings.HasSuffix(base, tempSuffix)
+}
+
+// createTemp creates a new hidden file next to dst, with the permissions perm
+// (before umask), to be renamed to dst once written.
+func createTemp(dst string, perm os.FileMode) (*os.File, error) {
+	dir, base := filepath.Split(dst)
+	for i := 0; ; i++ {
+		name := filepath.Join(dir, "."+base+"."+strconv.Itoa(os.Getpid())+"-"+strconv.Itoa(i)+tempSuffix)
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if !os.IsExist(err) || i >= 10000 {
+			return f, err
+		}
+	}
+}
+
+// compareOutput compares the existing file at dst with b, printing a unified
+// diff with --diff and recording whether they differ.
+func compareOutput(dst string, b []byte) error {
+	current, err := ioutil.ReadFile(filepath.Clean(dst))
+	fromFile := dst
+	if os.IsNotExist(err) {
+		fromFile = "/dev/null"
+	} else if err != nil {
+		return err
+	}
+	if bytes.Equal(current, b) {
+		return nil
+	}
+	compareMu.Lock()
+	defer compareMu.Unlock()
+	outdated = true
+	if !diffFlag {
+		log.Printf("Would update %s\n", dst)
+		return nil
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(current)),
+		B:        splitLines(string(b)),
+		FromFile: fromFile,
+		ToFile:   dst,
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Print(diff)
+	return nil
+}
+
+// splitLines splits s into lines, each keeping its trailing newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// parseOwner parses a --chown owner given as USER[:GROUP], where USER and GROUP
+// are names or numeric ids, into a uid and gid (-1 if not set).
+func parseOwner(s string) (int, int, error) {
+	uid, gid := -1, -1
+	name, group := s, ""
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		name, group = s[:i], s[i+1:]
+	}
+	if name != "" {
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return 0, 0, err
+			}
+			if id, err = strconv.Atoi(u.Uid); err != nil {
+				return 0, 0, fmt.Errorf("user %s has a non-numeric id %q", name, u.Uid)
+			}
+		}
+		uid = id
+	}
+	if group != "" {
+		id, err := strconv.Atoi(group)
+		if err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return 0, 0, err
+			}
+			if id, err = strconv.Atoi(g.Gid); err != nil {
+				return 0, 0, fmt.Errorf("group %s has a non-numeric id %q", group, g.Gid)
+			}
+		}
+		gid = id
+	}
+	if uid < 0 && gid < 0 {
+		return 0, 0, fmt.Errorf("%q doesn't specify a user or group", s)
+	}
+	return uid, gid, nil
+}
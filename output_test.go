@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os/user"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseOwner(t *testing.T) {
+	tests := []struct {
+		s        string
+		uid, gid int
+		err      string
+	}{
+		{"1000", 1000, -1, ""},
+		{"1000:", 1000, -1, ""},
+		{"1000:2000", 1000, 2000, ""},
+		{":2000", -1, 2000, ""},
+		{"0:0", 0, 0, ""},
+		{"", 0, 0, `"" doesn't specify a user or group`},
+		{":", 0, 0, `":" doesn't specify a user or group`},
+		{"no-such-user-datasubst", 0, 0, "no-such-user-datasubst"},
+		{":no-such-group-datasubst", 0, 0, "no-such-group-datasubst"},
+	}
+	if u, err := user.Current(); err == nil {
+		if uid, err := strconv.Atoi(u.Uid); err == nil {
+			tests = append(tests, struct {
+				s        string
+				uid, gid int
+				err      string
+			}{u.Username + ":7", uid, 7, ""})
+		}
+	}
+	for _, tt := range tests {
+		uid, gid, err := parseOwner(tt.s)
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("parseOwner(%q): got error %v, want %s", tt.s, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("parseOwner(%q): %v", tt.s, err)
+		case tt.err == "" && (uid != tt.uid || gid != tt.gid):
+			t.Errorf("parseOwner(%q) = %d, %d, want %d, %d", tt.s, uid, gid, tt.uid, tt.gid)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pairDataSuffixes are the suffixes of the per-template data files used by
+// --pair-data, in order of preference.
+var pairDataSuffixes = []string{".data.yaml", ".data.yml", ".data.json"}
+
+// isPairDataFile reports whether path is a per-template data file, which is
+// neither rendered nor copied with --pair-data.
+func isPairDataFile(path string) bool {
+	for _, suffix := range pairDataSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// pairedData returns data with the per-template data file of the template at
+// src merged over it, or data itself if there is none. The data file is named
+// after the template without its extension, e.g. foo.conf.data.yaml for
+// foo.conf.tmpl.
+func pairedData(src string, data interface{}) (interface{}, error) {
+	base := strings.TrimSuffix(src, filepath.Ext(src))
+	for _, suffix := range pairDataSuffixes {
+		b, err := ioutil.ReadFile(filepath.Clean(base + suffix))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if b, err = decodeText(b, dataEncoding); err != nil {
+			return nil, err
+		}
+		format := "yaml"
+		if strings.HasSuffix(suffix, ".json") {
+			format = "json"
+		}
+		d, err := decodeData(format, b)
+		if err != nil {
+			return nil, err
+		}
+		addSecrets(d)
+		// The global data is shared by all the templates
+		return mergeData(copyMaps(data), d, mergeStrategy == "deep")
+	}
+	return data, nil
+}
+
+// copyMaps returns a copy of v in which maps are copied recursively, so it can
+// be merged into without modifying v.
+func copyMaps(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, e := range m {
+		c[k] = copyMaps(e)
+	}
+	if keys, ok := recordedKeys(m); ok {
+		setKeyOrder(c, append([]string(nil), keys...))
+	}
+	return c
+}
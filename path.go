@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathElem is a single step in a data path: either a map key, a list index
+// or a wildcard ("[]") matching any element of a list or map.
+type pathElem struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func (e pathElem) String() string {
+	if e.wildcard {
+		return "[]"
+	}
+	if e.isIndex {
+		return "[" + strconv.Itoa(e.index) + "]"
+	}
+	if e.key == "" || strings.ContainsAny(e.key, ".[]\"' ") {
+		return "[" + strconv.Quote(e.key) + "]"
+	}
+	return "." + e.key
+}
+
+// parsePath parses a data path such as .items[2].name or .["a.b"].c into its
+// elements. Keys are separated by dots, list indices and quoted keys (which may
+// contain dots) are given in brackets. A lone "." refers to the whole data.
+func parsePath(path string) ([]pathElem, error) {
+	var elems []pathElem
+	s := path
+	if !strings.HasPrefix(s, ".") && !strings.HasPrefix(s, "[") {
+		s = "." + s
+	}
+	if s == "." {
+		return nil, nil
+	}
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, ".["):
+			s = s[1:]
+		case s[0] == '.':
+			n := strings.IndexAny(s[1:], ".[")
+			if n < 0 {
+				n = len(s) - 1
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("invalid path %q: empty key", path)
+			}
+			elems = append(elems, pathElem{key: s[1 : n+1]})
+			s = s[n+1:]
+		case s[0] == '[':
+			end, elem, err := parseBracket(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %v", path, err)
+			}
+			elems = append(elems, elem)
+			s = s[end:]
+		default:
+			return nil, fmt.Errorf("invalid path %q: unexpected %q", path, s[0])
+		}
+	}
+	return elems, nil
+}
+
+// parseBracket parses a bracketed index or quoted key at the start of s and
+// returns the offset just after the closing bracket.
+func parseBracket(s string) (int, pathElem, error) {
+	if len(s) > 1 && (s[1] == '"' || s[1] == '\'') {
+		quote := s[1]
+		i := 2
+		for ; i < len(s) && s[i] != quote; i++ {
+			if s[i] == '\\' && quote == '"' {
+				i++
+			}
+		}
+		if i+1 >= len(s) || s[i+1] != ']' {
+			return 0, pathElem{}, fmt.Errorf("unterminated quoted key")
+		}
+		key := s[2:i]
+		if quote == '"' {
+			var err error
+			if key, err = strconv.Unquote(s[1 : i+1]); err != nil {
+				return 0, pathElem{}, fmt.Errorf("invalid quoted key %s", s[1:i+1])
+			}
+		}
+		return i + 2, pathElem{key: key}, nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return 0, pathElem{}, fmt.Errorf("missing ']'")
+	}
+	if end == 1 {
+		return 2, pathElem{wildcard: true}, nil
+	}
+	index, err := strconv.Atoi(s[1:end])
+	if err != nil {
+		return 0, pathElem{}, fmt.Errorf("invalid index %q", s[1:end])
+	}
+	return end + 1, pathElem{index: index, isIndex: true}, nil
+}
+
+// lookupPath returns the value at path in data, or an error naming the first
+// element of the path that doesn't exist.
+func lookupPath(data interface{}, path string) (interface{}, error) {
+	elems, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	var walked strings.Builder
+	walked.WriteString(".")
+	for _, e := range elems {
+		if e.wildcard {
+			return nil, fmt.Errorf("invalid path %q: wildcards are not supported", path)
+		}
+		parent := walked.String()
+		if parent == "." && e.String()[0] == '.' {
+			walked.Reset()
+		}
+		walked.WriteString(e.String())
+		if e.isIndex {
+			l, ok := data.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("path %s not found: %s is not a list", walked.String(), parent)
+			}
+			if e.index < 0 || e.index >= len(l) {
+				return nil, fmt.Errorf("path %s not found: index out of range (length %d)", walked.String(), len(l))
+			}
+			data = l[e.index]
+			continue
+		}
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("path %s not found: %s is not a map", walked.String(), parent)
+		}
+		if data, ok = m[e.key]; !ok {
+			return nil, fmt.Errorf("path %s not found", walked.String())
+		}
+	}
+	return data, nil
+}
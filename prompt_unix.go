@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+)
+
+// readHidden reads a line from r, reading the terminal, with echo turned off.
+func readHidden(r *bufio.Reader) (string, error) {
+	if err := stty("-echo"); err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = stty("echo")
+	}()
+	return readLine(r)
+}
+
+// stty applies the setting arg to the terminal of the standard input.
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bufio"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// readHidden reads a line from r, reading the console, with echo turned off.
+func readHidden(r *bufio.Reader) (string, error) {
+	h := windows.Handle(os.Stdin.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return "", err
+	}
+	if err := windows.SetConsoleMode(h, mode&^windows.ENABLE_ECHO_INPUT); err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = windows.SetConsoleMode(h, mode)
+	}()
+	return readLine(r)
+}
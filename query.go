@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// applyQuery runs the jq expression q over data. A single result is returned
+// as is, multiple results are collected into a list.
+func applyQuery(data interface{}, q string) (interface{}, error) {
+	query, err := gojq.Parse(q)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %v", q, err)
+	}
+	var results []interface{}
+	iter := query.Run(normalizeData(data))
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return nil, fmt.Errorf("query %q: %v", q, err)
+		}
+		results = append(results, v)
+	}
+	switch len(results) {
+	case 0:
+		return nil, nil
+	case 1:
+		return results[0], nil
+	default:
+		return results, nil
+	}
+}
+
+// normalizeData converts values produced by the YAML and TOML decoders into
+// the JSON-like types expected by the query engine.
+func normalizeData(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeData(e)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalizeData(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeData(e)
+		}
+		return v
+	case []map[string]interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = normalizeData(e)
+		}
+		return l
+	case int64:
+		if int64(int(v)) == v {
+			return int(v)
+		}
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// shquote quotes v for POSIX shells, in single quotes, so it's a single word
+// whatever characters it contains: the quotes are closed around its own single
+// quotes, which are escaped.
+func shquote(v interface{}) string {
+	return "'" + strings.ReplaceAll(fmt.Sprint(v), "'", `'\''`) + "'"
+}
+
+// shjoin quotes each element of the list l with shquote and joins them with
+// spaces, e.g. for the arguments of a command.
+func shjoin(l interface{}) (string, error) {
+	var words []string
+	switch l := l.(type) {
+	case []interface{}:
+		for _, e := range l {
+			words = append(words, shquote(e))
+		}
+	case []string:
+		for _, e := range l {
+			words = append(words, shquote(e))
+		}
+	default:
+		return "", fmt.Errorf("shjoin requires a list, got %T", l)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// jsonString encodes s as a JSON string, without escaping HTML characters.
+func jsonString(s string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// jsonEscape escapes v to be embedded in a JSON string, i.e. between double
+// quotes.
+func jsonEscape(v interface{}) (string, error) {
+	s, err := jsonString(fmt.Sprint(v))
+	if err != nil {
+		return "", err
+	}
+	return s[1 : len(s)-1], nil
+}
+
+// yamlQuote quotes v as a double-quoted YAML string, so it's read back as the
+// same string whatever it contains (e.g. newlines, quotes, or "yes").
+func yamlQuote(v interface{}) (string, error) {
+	// JSON strings are valid double-quoted YAML strings
+	return jsonString(fmt.Sprint(v))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	mathrand "math/rand"
+	"sync"
+	"text/template"
+)
+
+// randomSource is the source of the random functions of a template set:
+// crypto/rand, or with --reproducible, a generator seeded by SOURCE_DATE_EPOCH
+// and the name of the set, so every template renders the same values
+// whatever the order templates are rendered in (e.g. with --workers).
+type randomSource struct {
+	mu sync.Mutex
+	r  *mathrand.Rand
+}
+
+// newRandomSource returns the random source of the template set name.
+func newRandomSource(name string) *randomSource {
+	if !reproducible {
+		return &randomSource{}
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:%s", reproducibleTime.Unix(), name)
+	return &randomSource{r: mathrand.New(mathrand.NewSource(int64(h.Sum64())))}
+}
+
+// Read fills b with random bytes.
+func (s *randomSource) Read(b []byte) (int, error) {
+	if s.r == nil {
+		return cryptorand.Read(b)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.r.Read(b)
+}
+
+// intn returns a uniform random number in [0, n).
+func (s *randomSource) intn(n int) (int, error) {
+	if s.r == nil {
+		i, err := cryptorand.Int(cryptorand.Reader, big.NewInt(int64(n)))
+		if err != nil {
+			return 0, err
+		}
+		return int(i.Int64()), nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.r.Intn(n), nil
+}
+
+// requireRandom returns an error unless --allow-random is set, as the random
+// values of the function name change the output of every run.
+func requireRandom(name string) error {
+	if !allowRandom {
+		return fmt.Errorf("%s requires --allow-random", name)
+	}
+	return nil
+}
+
+// randomFuncs returns the random functions using the source s.
+func randomFuncs(s *randomSource) template.FuncMap {
+	return template.FuncMap{
+		"uuidv4": func() (string, error) {
+			if err := requireRandom("uuidv4"); err != nil {
+				return "", err
+			}
+			return uuidv4(s)
+		},
+		"randAlphaNum": func(n interface{}) (string, error) {
+			if err := requireRandom("randAlphaNum"); err != nil {
+				return "", err
+			}
+			return randAlphaNum(s, n)
+		},
+		"randBytes": func(n interface{}, encoding ...string) (string, error) {
+			if err := requireRandom("randBytes"); err != nil {
+				return "", err
+			}
+			return randBytes(s, n, encoding...)
+		},
+	}
+}
+
+// uuidv4 returns a random (version 4) UUID.
+func uuidv4(s *randomSource) (string, error) {
+	b := make([]byte, 16)
+	if _, err := s.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+const alphaNum = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// randAlphaNum returns a random string of n letters and digits, e.g. for
+// generated passwords or tokens.
+func randAlphaNum(s *randomSource, n interface{}) (string, error) {
+	length, err := toInt(n)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 {
+		return "", fmt.Errorf("invalid length %d", length)
+	}
+	b := make([]byte, length)
+	for i := range b {
+		j, err := s.intn(len(alphaNum))
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphaNum[j]
+	}
+	return string(b), nil
+}
+
+// randBytes returns n random bytes, encoded in base64 (by default) or hex.
+func randBytes(s *randomSource, n interface{}, encoding ...string) (string, error) {
+	length, err := toInt(n)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 {
+		return "", fmt.Errorf("invalid length %d", length)
+	}
+	enc := "base64"
+	if len(encoding) > 0 {
+		enc = encoding[0]
+	}
+	b := make([]byte, length)
+	if _, err := s.Read(b); err != nil {
+		return "", err
+	}
+	switch enc {
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b), nil
+	case "hex":
+		return hex.EncodeToString(b), nil
+	}
+	return "", fmt.Errorf("invalid encoding %q, must be 'base64' or 'hex'", enc)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	// rawBlockRes caches the regexps matching the raw and endraw markers of
+	// each pair of delimiters, guarded by rawBlockMu as templates are parsed
+	// concurrently with --workers.
+	rawBlockRes = make(map[[2]string][2]*regexp.Regexp)
+	rawBlockMu  sync.Mutex
+)
+
+// rawMarkers returns the regexps matching the {{ raw }} and {{ endraw }}
+// markers written with the delimiters left and right, with optional trim
+// markers.
+func rawMarkers(left, right string) (*regexp.Regexp, *regexp.Regexp) {
+	rawBlockMu.Lock()
+	defer rawBlockMu.Unlock()
+	key := [2]string{left, right}
+	if res, ok := rawBlockRes[key]; ok {
+		return res[0], res[1]
+	}
+	marker := func(word string) *regexp.Regexp {
+		return regexp.MustCompile(regexp.QuoteMeta(left) + `(-[ \t])?[ \t]*` + word + `[ \t]*?([ \t]-)?` + regexp.QuoteMeta(right))
+	}
+	res := [2]*regexp.Regexp{marker("raw"), marker("endraw")}
+	rawBlockRes[key] = res
+	return res[0], res[1]
+}
+
+// expandRawBlocks rewrites the raw blocks of text, written with the delimiters
+// left and right (e.g. {{ raw }}{{ .literal }}{{ endraw }}), into actions
+// printing their content as is, so templates can output the delimiters (e.g.
+// Helm charts or GitHub Actions workflows) without changing them. Trim markers
+// apply as usual ({{- raw }} trims the whitespace before the block and
+// {{ raw -}} the whitespace at the start of its content), and lines are
+// preserved so that errors refer to the lines of text.
+func expandRawBlocks(text, left, right string) (string, error) {
+	if left == "" {
+		left, right = "{{", "}}"
+	}
+	if !strings.Contains(text, "raw") {
+		return text, nil
+	}
+	begin, end := rawMarkers(left, right)
+	var b strings.Builder
+	for {
+		loc := begin.FindStringSubmatchIndex(text)
+		if loc == nil {
+			b.WriteString(text)
+			return b.String(), nil
+		}
+		rest := text[loc[1]:]
+		endLoc := end.FindStringSubmatchIndex(rest)
+		if endLoc == nil {
+			line := strings.Count(b.String()+text[:loc[0]], "\n") + 1
+			return "", fmt.Errorf("line %d: %s is not closed by %s", line, text[loc[0]:loc[1]], left+" endraw "+right)
+		}
+		content := rest[:endLoc[0]]
+		var leading, trailing string
+		if loc[4] >= 0 {
+			trimmed := strings.TrimLeft(content, " \t\r\n")
+			leading, content = content[:len(content)-len(trimmed)], trimmed
+		}
+		if endLoc[2] >= 0 {
+			trimmed := strings.TrimRight(content, " \t\r\n")
+			trailing, content = content[len(trimmed):], trimmed
+		}
+
+		b.WriteString(text[:loc[0]])
+		b.WriteString(left)
+		if loc[2] >= 0 {
+			b.WriteString("- ")
+		} else {
+			b.WriteString(" ")
+		}
+		// The trimmed whitespace is kept within the action, where it's
+		// ignored, to preserve the lines
+		b.WriteString(spaceLines(leading))
+		b.WriteString(rawLiteral(content))
+		b.WriteString(spaceLines(trailing))
+		if endLoc[4] >= 0 {
+			b.WriteString(" -")
+		} else {
+			b.WriteString(" ")
+		}
+		b.WriteString(right)
+		text = rest[endLoc[1]:]
+	}
+}
+
+// spaceLines returns the newlines of s, or a space.
+func spaceLines(s string) string {
+	if n := strings.Count(s, "\n"); n > 0 {
+		return strings.Repeat("\n", n)
+	}
+	return " "
+}
+
+// rawLiteral returns a template expression evaluating to s. Raw strings
+// (which preserve newlines) can't contain backquotes, and their carriage
+// returns are discarded, so these are printed as quoted strings.
+func rawLiteral(s string) string {
+	var parts []string
+	start := 0
+	for i, r := range s {
+		if r != '`' && r != '\r' {
+			continue
+		}
+		if i > start {
+			parts = append(parts, "`"+s[start:i]+"`")
+		}
+		parts = append(parts, fmt.Sprintf("%q", string(r)))
+		start = i + 1
+	}
+	if start < len(s) || len(parts) == 0 {
+		parts = append(parts, "`"+s[start:]+"`")
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "print " + strings.Join(parts, " ")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// loadRedisHash returns the fields of the Redis hash at key. When key is a
+// pattern (containing '*', '?' or '['), the matching keys are scanned instead
+// and the data maps each key to its value: the fields of a hash or the value
+// of a string.
+func loadRedisHash(key string) (map[string]interface{}, error) {
+	client, err := newRedisClient(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("redis %s: %w", redactURL(redisURL), err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if !strings.ContainsAny(key, "*?[") {
+		fields, err := client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("redis key %s: %w", key, err)
+		}
+		return redisMap(fields), nil
+	}
+
+	data := make(map[string]interface{})
+	it := client.Scan(ctx, 0, key, 100).Iterator()
+	for it.Next(ctx) {
+		name := it.Val()
+		if data[name], err = redisValue(ctx, client, name); err != nil {
+			return nil, fmt.Errorf("redis key %s: %w", name, err)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, fmt.Errorf("redis scan %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// redisLogger discards the logs of the Redis client, whose errors are
+// returned instead.
+type redisLogger struct{}
+
+var redisLoggerOnce sync.Once
+
+func (redisLogger) Printf(ctx context.Context, format string, v ...interface{}) {}
+
+// newRedisClient returns a client of the Redis server at rawURL (redis://
+// [[USER]:PASSWORD@]HOST[:PORT][/DB], or rediss:// for TLS with the TLS
+// options). Connecting and each command time out after --http-timeout, and
+// failures are retried with --retries rather than by the client.
+func newRedisClient(rawURL string) (*redis.Client, error) {
+	redisLoggerOnce.Do(func() { redis.SetLogger(redisLogger{}) })
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TLSConfig != nil {
+		serverName := opts.TLSConfig.ServerName
+		if opts.TLSConfig, err = newTLSConfig(); err != nil {
+			return nil, err
+		}
+		opts.TLSConfig.ServerName = serverName
+	}
+	opts.DialTimeout = httpTimeout
+	opts.ReadTimeout = httpTimeout
+	opts.WriteTimeout = httpTimeout
+	opts.MaxRetries = -1
+	return redis.NewClient(opts), nil
+}
+
+// redisValue returns the fields of the hash or the value of the string at key.
+func redisValue(ctx context.Context, client *redis.Client, key string) (interface{}, error) {
+	t, err := client.Type(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case "hash":
+		fields, err := client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		return redisMap(fields), nil
+	case "string":
+		return client.Get(ctx, key).Result()
+	}
+	return nil, fmt.Errorf("unsupported type %v, must be a hash or a string", t)
+}
+
+// redisMap turns the fields of a hash into data.
+func redisMap(fields map[string]string) map[string]interface{} {
+	m := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		m[k] = v
+	}
+	return m
+}
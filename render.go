@@ -0,0 +1,486 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// executor is a parsed template that can be rendered against data.
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// parseTemplate parses text using the template syntax selected on the command
+// line: go templates by default, or shell format with --shell-format.
+func parseTemplate(name, text string) (executor, error) {
+	return parseTemplateWith(globalTemplateOptions(), name, text)
+}
+
+// parseTemplateWith is like parseTemplate, parsing go templates with opts.
+func parseTemplateWith(opts templateOptions, name, text string) (executor, error) {
+	if shellFormat {
+		return parseShellTemplate(text)
+	}
+	return newTemplateWith(opts, name, text)
+}
+
+// templateOptions are the options go templates are parsed with: the global
+// ones, possibly overridden by the front matter of a template.
+type templateOptions struct {
+	leftDelim, rightDelim, missingKey string
+	// lineOffset is the number of lines preceding the template text in its
+	// file (its front matter), for error messages
+	lineOffset int
+}
+
+// globalTemplateOptions returns the template options set on the command line.
+func globalTemplateOptions() templateOptions {
+	return templateOptions{leftDelim: leftDelim, rightDelim: rightDelim, missingKey: missingKey}
+}
+
+// execute renders tpl against data and validates the result when --validate
+// is set.
+func execute(tpl executor, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, describeTemplateError(err, tpl)
+	}
+	b := chompNewline(buf.Bytes())
+	if err := validateOutput(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// templateSource is the name and text of a template, the delimiters it is
+// parsed with, and the number of lines preceding the text in its file.
+type templateSource struct {
+	name, text            string
+	leftDelim, rightDelim string
+	lineOffset            int
+}
+
+// newTemplate parses text into a template configured with the global template
+// options (missing key policy and delimiters), along with the others templates
+// (e.g. repeated --input files) and those matching --template-glob, so they
+// can be used with {{ template "name" . }}.
+func newTemplate(name, text string, others ...templateSource) (*template.Template, error) {
+	return newTemplateWith(globalTemplateOptions(), name, text, others...)
+}
+
+// newTemplateWith is like newTemplate, configuring the template with opts.
+func newTemplateWith(opts templateOptions, name, text string, others ...templateSource) (*template.Template, error) {
+	tpl := newTemplateSet(opts, name, newRandomSource(name))
+	expanded, err := expandTemplateText(text, opts.leftDelim, opts.rightDelim)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	registerTemplateText(name, text, expanded, opts.lineOffset)
+	tpl, err = tpl.Parse(expanded)
+	if err != nil {
+		return nil, describeTemplateError(err, nil)
+	}
+	for _, o := range others {
+		expanded, err := expandTemplateText(o.text, o.leftDelim, o.rightDelim)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", o.name, err)
+		}
+		registerTemplateText(o.name, o.text, expanded, o.lineOffset)
+		if _, err := tpl.New(o.name).Delims(o.leftDelim, o.rightDelim).Parse(expanded); err != nil {
+			return nil, describeTemplateError(err, nil)
+		}
+	}
+	for _, pattern := range templateGlobs {
+		if err := parseGlob(tpl, pattern, opts); err != nil {
+			return nil, err
+		}
+	}
+	rewriteTemplates(tpl, opts)
+	return tpl, nil
+}
+
+// newTemplateSet returns an empty template set named name, with the functions
+// and options of opts, and random functions using rand.
+func newTemplateSet(opts templateOptions, name string, rand *randomSource) *template.Template {
+	tpl := template.New(name).Funcs(templateFuncs()).Funcs(randomFuncs(rand)).Funcs(cryptoFuncs())
+	tpl.Funcs(template.FuncMap{"include": includeFunc(tpl), tplFuncName: tplFunc(tpl, opts, rand)})
+	if opts.missingKey == "error" {
+		tpl.Option("missingkey=error")
+	}
+	if rewriteMissing(opts.missingKey) {
+		tpl.Funcs(missingFuncs(opts.missingKey))
+	}
+	if traceFlag {
+		tpl.Funcs(traceFuncs())
+	}
+	if ignoreCase {
+		tpl.Funcs(ignoreCaseFuncs(opts.missingKey))
+	}
+	if sourceOrder() {
+		tpl.Funcs(mapOrderFuncs())
+	}
+	if opts.leftDelim != "" {
+		tpl.Delims(opts.leftDelim, opts.rightDelim)
+	}
+	return tpl
+}
+
+// rewriteTemplates rewrites the parsed templates of tpl as needed by the
+// options (e.g. --trace, --ignore-case or --map-order).
+func rewriteTemplates(tpl *template.Template, opts templateOptions) {
+	// Values are traced before the missing key policy applies
+	if traceFlag {
+		traceTemplate(tpl)
+	}
+	if rewriteMissing(opts.missingKey) {
+		missingTemplate(tpl)
+	}
+	// Last, so that the expressions logged and reported are those written
+	if ignoreCase {
+		ignoreCaseTemplate(tpl)
+	}
+	if sourceOrder() {
+		mapOrderTemplate(tpl)
+	}
+}
+
+// parseGlob parses the template files matching pattern into the set of tpl,
+// like tpl.ParseGlob, naming them after their base name, and expanding their
+// raw blocks and whitespace control.
+func parseGlob(tpl *template.Template, pattern string, opts templateOptions) error {
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("template: pattern matches no files: %#q", pattern)
+	}
+	for _, name := range names {
+		b, err := ioutil.ReadFile(filepath.Clean(name))
+		if err != nil {
+			return err
+		}
+		if b, err = decodeInput(name, b); err != nil {
+			return err
+		}
+		text, err := expandTemplateText(string(b), opts.leftDelim, opts.rightDelim)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if _, err := tpl.New(filepath.Base(name)).Parse(text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandTemplateText expands the raw blocks of text, written with the
+// delimiters left and right, and applies --trim-blocks and --lstrip-blocks.
+func expandTemplateText(text, left, right string) (string, error) {
+	expanded, err := expandRawBlocks(text, left, right)
+	if err != nil {
+		return "", err
+	}
+	return trimBlockWhitespace(expanded, left, right), nil
+}
+
+// renderFile renders the template at src into dst, writing dst with the given
+// file mode. The front matter of the template can set another dst.
+func renderFile(src, dst string, mode os.FileMode, data interface{}) error {
+	start := time.Now()
+	tplStr, err := ioutil.ReadFile(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	text, err := decodeInput(src, tplStr)
+	if err != nil {
+		return err
+	}
+	tpl, fm, err := parseTemplateFile(src, string(text))
+	if err != nil {
+		return inFile(src, err)
+	}
+	backup := backupFlag && src == dst
+	if p := fm.outputPath(filepath.Dir(dst)); p != "" {
+		dst = p
+	}
+	b, err := execute(tpl, data)
+	if err != nil {
+		return inFile(src, err)
+	}
+	if len(bytes.TrimSpace(b)) == 0 && writesFiles(tpl) {
+		return nil
+	}
+	if b, err = withHeader(src, data, b); err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	b = convertNewlines(b, newlineStyle(text))
+	if backup && !dryRunMode() {
+		// Next to the source, as the front matter may set another dst
+		if err := ioutil.WriteFile(src+".bak", tplStr, mode); err != nil {
+			return err
+		}
+	}
+	return writeReported(src, dst, mode, b, start)
+}
+
+// inFile prefixes err with the template file src, unless it's a
+// templateError, which already names it.
+func inFile(src string, err error) error {
+	var tplErr *templateError
+	if errors.As(err, &tplErr) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", src, err)
+}
+
+// renderOrCopy renders the template at src (at the relative path rel in the
+// input) into dst. Only the files matching --include (if any) and ending with
+// --strip-suffix (if set, and removed from dst) are templates; any other file,
+// including binary files, is copied as is.
+func renderOrCopy(src, rel, dst string, mode os.FileMode, data interface{}) error {
+	isTemplate := len(includes) == 0 || matchAny(includes, rel)
+	if stripSuffix != "" {
+		if strings.HasSuffix(dst, stripSuffix) && len(filepath.Base(dst)) > len(stripSuffix) {
+			dst = strings.TrimSuffix(dst, stripSuffix)
+		} else {
+			isTemplate = false
+		}
+	}
+	if isTemplate {
+		binary, err := isBinaryFile(src)
+		if err != nil {
+			return err
+		}
+		isTemplate = !binary
+	}
+	switch {
+	case isTemplate && pairData:
+		d, err := pairedData(src, data)
+		if err != nil {
+			return fmt.Errorf("%s: reading paired data: %w", src, err)
+		}
+		return renderFile(src, dst, mode, d)
+	case isTemplate:
+		return renderFile(src, dst, mode, data)
+	case src == dst:
+		return nil
+	}
+	return copyFile(src, dst, mode)
+}
+
+// sniffLen is the number of bytes inspected to detect binary files.
+const sniffLen = 8000
+
+// isBinary reports whether b looks like the start of a binary file: it contains
+// a null byte or isn't detected as text, and isn't UTF-16 text.
+func isBinary(b []byte) bool {
+	if len(b) > sniffLen {
+		b = b[:sniffLen]
+	}
+	if isUTF16(b) {
+		return false
+	}
+	return bytes.IndexByte(b, 0) >= 0 || !strings.HasPrefix(http.DetectContentType(b), "text/")
+}
+
+// isBinaryFile reports whether the file at path is a binary file.
+func isBinaryFile(path string) (bool, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	b := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return isBinary(b[:n]), nil
+}
+
+// copyFile copies src into dst, writing dst with the given file mode.
+func copyFile(src, dst string, mode os.FileMode) error {
+	start := time.Now()
+	b, err := ioutil.ReadFile(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	return writeReported(src, dst, mode, b, start)
+}
+
+// fileJob is a file of an input directory to render (or copy) into dst.
+type fileJob struct {
+	src, rel, dst string
+	mode          os.FileMode
+}
+
+// renderDir walks srcDir and renders every regular file into the same relative
+// path under dstDir, preserving file and directory modes. Files and directories
+// matching --exclude or the patterns in .datasubstignore are skipped. The
+// relative paths are rendered as templates as well, so e.g.
+// {{ .service }}/deploy.yaml is written to my-service/deploy.yaml. With
+// --pair-data, per-template data files are skipped, and with --backup, the
+// .bak files of previous runs are. Files are rendered by
+// --workers goroutines once all the directories are created.
+func renderDir(srcDir, dstDir string, data interface{}) error {
+	rules, err := readIgnoreFile(srcDir)
+	if err != nil {
+		return err
+	}
+	var dirs []string
+	var modes []os.FileMode
+	var jobs []fileJob
+	var pathErrs []error
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && (matchAny(excludes, rel) || ignored(rules, rel, info.IsDir())) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		srcRel := rel
+		// Output paths are templates too, unless rendering in place
+		if srcDir != dstDir {
+			if rel, err = renderPath(rel, data); err != nil {
+				if !keepGoing {
+					return err
+				}
+				pathErrs = append(pathErrs, err)
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		dst := filepath.Join(dstDir, rel)
+		if info.IsDir() {
+			if dryRunMode() {
+				return nil
+			}
+			dirs = append(dirs, dst)
+			modes = append(modes, info.Mode().Perm())
+			return os.MkdirAll(dst, 0755)
+		}
+		if !info.Mode().IsRegular() || pairData && isPairDataFile(path) || backupFlag && strings.HasSuffix(path, ".bak") {
+			return nil
+		}
+		jobs = append(jobs, fileJob{src: path, rel: srcRel, dst: dst, mode: info.Mode().Perm()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := renderJobs(jobs, data, pathErrs); err != nil {
+		return err
+	}
+	// Directory modes are applied last (deepest first) so read-only source
+	// directories don't prevent their contents from being written.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], modes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderPath renders the relative path rel as a template against data.
+func renderPath(rel string, data interface{}) (string, error) {
+	tpl, err := parseTemplate(rel, rel)
+	if err != nil {
+		return "", fmt.Errorf("parsing file name %s: %w", rel, err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering file name %s: %w", rel, err)
+	}
+	p := filepath.Clean(buf.String())
+	if p == "." && rel != "." || !isLocalPath(p) {
+		return "", fmt.Errorf("file name %s rendered to invalid path %q", rel, buf.String())
+	}
+	return p, nil
+}
+
+// isLocalPath reports whether the clean path p is relative and stays within
+// the directory it's relative to, i.e. doesn't start with "..".
+func isLocalPath(p string) bool {
+	return !filepath.IsAbs(p) && filepath.VolumeName(p) == "" && p != ".." && !strings.HasPrefix(p, ".."+string(filepath.Separator))
+}
+
+// renderJob renders (or copies) the file of j, adding it to the --report
+// summary if it fails.
+func renderJob(j fileJob, data interface{}) error {
+	start := time.Now()
+	err := renderOrCopy(j.src, j.rel, j.dst, j.mode, data)
+	if err != nil {
+		addReportEntry(reportEntry{Source: j.src, Destination: j.dst, Error: maskSecrets(err.Error())}, start)
+	}
+	return err
+}
+
+// renderJobs renders jobs using up to --workers goroutines, returning the error
+// of the first failed job (in walk order). With a single worker, rendering
+// stops at the first error. With --keep-going, all the jobs are rendered and
+// the errors of all the failed ones (after those in errs, from the walk) are
+// reported together.
+func renderJobs(jobs []fileJob, data interface{}, errs []error) error {
+	total := len(jobs) + len(errs)
+	if workers <= 1 && !keepGoing {
+		for _, j := range jobs {
+			if err := renderJob(j, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	jobErrs := make([]error, len(jobs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				jobErrs[i] = renderJob(jobs[i], data)
+			}
+		}()
+	}
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for _, err := range jobErrs {
+		if err != nil {
+			if !keepGoing {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Errorf("%d of %d files failed:\n%s", len(errs), total, strings.Join(msgs, "\n"))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// report is the JSON summary written with --report.
+type report struct {
+	Files    []reportEntry `json:"files"`
+	Warnings []string      `json:"warnings"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// reportEntry is an output in the --report summary.
+type reportEntry struct {
+	Source      string  `json:"source,omitempty"`
+	Destination string  `json:"destination"`
+	Bytes       int     `json:"bytes"`
+	DurationMs  float64 `json:"durationMs"`
+	// Changed is false if the destination already had the same content
+	Changed bool `json:"changed"`
+	// Error is set for the files that failed to render with --keep-going
+	Error string `json:"error,omitempty"`
+}
+
+var (
+	// renderReport is the summary of the current render, guarded by reportMu
+	// as outputs are written concurrently with --workers.
+	renderReport report
+	reportMu     sync.Mutex
+	// renderStart is when the current render started.
+	renderStart time.Time
+)
+
+// resetReport starts the summary of a new render.
+func resetReport() {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	renderReport = report{Files: []reportEntry{}, Warnings: []string{}}
+	renderStart = time.Now()
+}
+
+// addReportEntry adds e to the summary, rendered since start.
+func addReportEntry(e reportEntry, start time.Time) {
+	if reportFile == "" {
+		return
+	}
+	// Durations vary from one render to another
+	if !reproducible {
+		e.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	}
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	renderReport.Files = append(renderReport.Files, e)
+}
+
+// writeReported writes b to dst like writeOutput, adding it to the summary as
+// rendered from src since start once written.
+func writeReported(src, dst string, mode os.FileMode, b []byte, start time.Time) error {
+	if reportFile == "" {
+		return writeOutput(dst, mode, b)
+	}
+	current, err := ioutil.ReadFile(filepath.Clean(dst))
+	changed := err != nil || !bytes.Equal(current, b)
+	if err := writeOutput(dst, mode, b); err != nil {
+		return err
+	}
+	addReportEntry(reportEntry{Source: src, Destination: dst, Bytes: len(b), Changed: changed}, start)
+	return nil
+}
+
+// warningWriter is the log output with --report, adding the logged warnings
+// to the summary.
+type warningWriter struct {
+	out io.Writer
+}
+
+func (w warningWriter) Write(p []byte) (int, error) {
+	if msg := string(p); strings.HasPrefix(msg, "Warning: ") {
+		reportMu.Lock()
+		msg = maskSecrets(strings.TrimSpace(strings.TrimPrefix(msg, "Warning: ")))
+		renderReport.Warnings = append(renderReport.Warnings, msg)
+		reportMu.Unlock()
+	}
+	return w.out.Write(p)
+}
+
+// writeReport writes the summary of the render, which failed with renderErr
+// if not nil, to --report. It's written even with --dry-run or --diff.
+func writeReport(renderErr error) error {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	if renderErr != nil {
+		renderReport.Error = maskSecrets(renderErr.Error())
+	}
+	// Files rendered concurrently are added in any order
+	sort.SliceStable(renderReport.Files, func(i, j int) bool {
+		return renderReport.Files[i].Destination < renderReport.Files[j].Destination
+	})
+	b, err := json.MarshalIndent(renderReport, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(reportFile, append(b, '\n'), 0666); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sourceDateEpoch is the time set by the SOURCE_DATE_EPOCH environment
+// variable (see https://reproducible-builds.org/specs/source-date-epoch/),
+// or the Unix epoch if not set, used as the clock with --reproducible.
+func sourceDateEpoch() (time.Time, error) {
+	s, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok || s == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q, must be a number of seconds", s)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+// renderTime returns the current time, or the fixed time of SOURCE_DATE_EPOCH
+// with --reproducible.
+func renderTime() time.Time {
+	if reproducible {
+		return reproducibleTime
+	}
+	return time.Now()
+}
+
+// setOutputTime sets the modification time of the output file at dst to
+// SOURCE_DATE_EPOCH with --reproducible.
+func setOutputTime(dst string) error {
+	if !reproducible {
+		return nil
+	}
+	return os.Chtimes(dst, reproducibleTime, reproducibleTime)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sort"
+)
+
+// scaffoldPlaceholder is the value of every key of the data skeleton generated
+// by the scaffold command.
+const scaffoldPlaceholder = "TODO"
+
+// scaffoldData returns a data skeleton containing the data paths in keys (as
+// collected by collectKeys), with placeholder values. Lists hold a single
+// element, describing all the elements.
+func scaffoldData(keys map[string]bool) interface{} {
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	var skeleton interface{} = make(map[string]interface{})
+	for _, k := range sorted {
+		elems, err := parsePath(k)
+		if err != nil || len(elems) == 0 {
+			continue
+		}
+		skeleton = scaffoldPath(skeleton, elems)
+	}
+	return skeleton
+}
+
+// scaffoldPath returns v with the data path elems added. Placeholders are
+// replaced by maps or lists when a path goes through them.
+func scaffoldPath(v interface{}, elems []pathElem) interface{} {
+	if len(elems) == 0 {
+		if v == nil {
+			return scaffoldPlaceholder
+		}
+		return v
+	}
+	if e := elems[0]; !e.isIndex && !e.wildcard {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			m = make(map[string]interface{})
+		}
+		m[e.key] = scaffoldPath(m[e.key], elems[1:])
+		return m
+	}
+	l, ok := v.([]interface{})
+	if !ok {
+		l = []interface{}{nil}
+	}
+	l[0] = scaffoldPath(l[0], elems[1:])
+	return l
+}
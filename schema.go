@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultKubernetesSchemaLocation is where the schemas of Kubernetes kinds
+// are fetched from by default, using the same template fields as kubeconform.
+const defaultKubernetesSchemaLocation = "https://raw.githubusercontent.com/yannh/kubernetes-json-schema/master/" +
+	"{{ .NormalizedKubernetesVersion }}-standalone{{ .StrictSuffix }}/{{ .ResourceKind }}{{ .KindSuffix }}.json"
+
+// schemas caches the schemas loaded by location ("" for missing Kubernetes
+// schemas), as outputs may be validated concurrently with --workers.
+var schemas = struct {
+	sync.Mutex
+	m map[string]*jsonschema.Schema
+}{m: make(map[string]*jsonschema.Schema)}
+
+// validateSchemas validates every YAML (or JSON) document of the rendered
+// output b against --validate-schema and, with --validate-kubernetes, the
+// schema of its Kubernetes kind, reporting all the violations found.
+func validateSchemas(b []byte) error {
+	docs, err := decodeDocuments(b)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for i, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		name := fmt.Sprintf("document %d", i+1)
+		if validateSchemaFile != "" {
+			schema, err := loadSchema(validateSchemaFile)
+			if err != nil {
+				return err
+			}
+			errs = append(errs, validateAgainst(name, schema, doc)...)
+		}
+		if validateKubernetes {
+			kind, location, err := kubernetesSchemaLocation(doc)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+				continue
+			}
+			schema, err := loadSchema(location)
+			if err != nil {
+				return err
+			}
+			if schema == nil {
+				log.Printf("Warning: %s: no schema found for %s, skipping validation\n", name, kind)
+				continue
+			}
+			errs = append(errs, validateAgainst(name+" ("+kind+")", schema, doc)...)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("output does not match its schema:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// schemaPrinter formats the messages of schema violations.
+var schemaPrinter = message.NewPrinter(language.English)
+
+// validateAgainst validates doc against schema, returning the violations
+// prefixed with name.
+func validateAgainst(name string, schema *jsonschema.Schema, doc interface{}) []string {
+	err := schema.Validate(doc)
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		if err != nil {
+			return []string{name + ": " + err.Error()}
+		}
+		return nil
+	}
+	var errs []string
+	var collect func(e *jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			errs = append(errs, fmt.Sprintf("%s: %s: %s", name, instancePath(doc, e.InstanceLocation), e.ErrorKind.LocalizedString(schemaPrinter)))
+		}
+		for _, c := range e.Causes {
+			collect(c)
+		}
+	}
+	collect(verr)
+	return errs
+}
+
+// instancePath formats the location of a value within doc, given as the keys
+// and indexes leading to it, as a data path (e.g. .spec.ports[0]).
+func instancePath(doc interface{}, location []string) string {
+	if len(location) == 0 {
+		return "."
+	}
+	var path strings.Builder
+	for _, token := range location {
+		switch v := doc.(type) {
+		case []interface{}:
+			i, _ := strconv.Atoi(token)
+			path.WriteString(pathElem{index: i, isIndex: true}.String())
+			if i >= 0 && i < len(v) {
+				doc = v[i]
+			}
+		case map[string]interface{}:
+			path.WriteString(pathElem{key: token}.String())
+			doc = v[token]
+		default:
+			path.WriteString(pathElem{key: token}.String())
+		}
+	}
+	return path.String()
+}
+
+// decodeDocuments decodes the YAML documents of b (a JSON document being
+// valid YAML) into JSON values.
+func decodeDocuments(b []byte) ([]interface{}, error) {
+	var docs []interface{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("output is not valid YAML: %v", err)
+		}
+		if v, err = toJSONValue(v); err != nil {
+			return nil, fmt.Errorf("output can't be validated against a schema: %v", err)
+		}
+		docs = append(docs, v)
+	}
+}
+
+// toJSONValue converts a decoded YAML value into its JSON representation,
+// with float64 numbers.
+func toJSONValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// loadSchema returns the compiled JSON (or YAML) schema at location, a file
+// path or an HTTP(S) URL. Missing schemas are nil when validating Kubernetes
+// kinds. Schemas follow the draft of their $schema (2020-12 by default), and
+// their $refs to other schemas must be local files.
+func loadSchema(location string) (*jsonschema.Schema, error) {
+	schemas.Lock()
+	defer schemas.Unlock()
+	if schema, ok := schemas.m[location]; ok {
+		return schema, nil
+	}
+	var b []byte
+	var err error
+	if isURL(location) {
+		b, err = cached(location, func() ([]byte, error) {
+			return fetchRemote(redactURL(location), func() ([]byte, error) {
+				return readDataSource(location)
+			})
+		})
+	} else {
+		b, err = readDataSource(location)
+	}
+	var status *statusError
+	if location != validateSchemaFile && (os.IsNotExist(err) || errors.As(err, &status) && status.code == http.StatusNotFound) {
+		schemas.m[location] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading schema %s: %w", redactURL(location), err)
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parsing schema %s: %w", redactURL(location), err)
+	}
+	if doc, err = toJSONValue(doc); err != nil {
+		return nil, fmt.Errorf("parsing schema %s: %w", redactURL(location), err)
+	}
+	// Resources are named after the redacted location, which errors mention
+	url := redactURL(location)
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(url, doc); err != nil {
+		return nil, fmt.Errorf("loading schema %s: %w", url, err)
+	}
+	schema, err := c.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("compiling schema %s: %w", url, err)
+	}
+	schemas.m[location] = schema
+	return schema, nil
+}
+
+// kubernetesSchemaLocation returns the kind of the Kubernetes resource doc
+// and the location of its schema, rendered from --kubernetes-schema-location.
+func kubernetesSchemaLocation(doc interface{}) (string, string, error) {
+	m, _ := doc.(map[string]interface{})
+	apiVersion, _ := m["apiVersion"].(string)
+	kind, _ := m["kind"].(string)
+	if apiVersion == "" || kind == "" {
+		return "", "", errors.New("not a Kubernetes resource, apiVersion and kind are required")
+	}
+	group, version := "", apiVersion
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		group, version = apiVersion[:i], apiVersion[i+1:]
+	}
+	kindSuffix := "-" + version
+	if group != "" {
+		kindSuffix = "-" + strings.ToLower(strings.Split(group, ".")[0]) + kindSuffix
+	}
+	k8sVersion := kubernetesVersion
+	if k8sVersion != "master" && !strings.HasPrefix(k8sVersion, "v") {
+		k8sVersion = "v" + k8sVersion
+	}
+	tpl, err := template.New("schema-location").Parse(kubeSchemaLocation)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing --kubernetes-schema-location: %w", err)
+	}
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, map[string]string{
+		"NormalizedKubernetesVersion": k8sVersion,
+		"StrictSuffix":                "",
+		"ResourceKind":                strings.ToLower(kind),
+		"ResourceAPIVersion":          version,
+		"Group":                       group,
+		"KindSuffix":                  kindSuffix,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("rendering --kubernetes-schema-location: %w", err)
+	}
+	return apiVersion + "/" + kind, buf.String(), nil
+}
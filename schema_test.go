@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchemas(t *testing.T) {
+	const schema = `{
+  "type": "object",
+  "required": ["name"],
+  "additionalProperties": false,
+  "properties": {
+    "name": {"type": "string", "pattern": "^[a-z]+$"},
+    "replicas": {"type": "integer", "minimum": 1},
+    "ports": {"type": "array", "items": {"$ref": "#/$defs/port"}},
+    "labels": {"patternProperties": {"^app\\.": {"type": "string"}}}
+  },
+  "$defs": {"port": {"type": "integer", "maximum": 65535}}
+}`
+	tests := []struct {
+		output string
+		errs   []string
+	}{
+		{"name: app\nreplicas: 2\nports: [80, 443]", nil},
+		{"name: app\n---\nname: web\nlabels: {app.tier: web}", nil},
+		{"replicas: 2", []string{"document 1: .: missing property 'name'"}},
+		{"name: App", []string{"document 1: .name: 'App' does not match pattern '^[a-z]+$'"}},
+		{"name: app\nreplicas: 1.5", []string{"document 1: .replicas: got number, want integer"}},
+		{"name: app\nports: [80, 70000]", []string{"document 1: .ports[1]: maximum: got 70,000, want 65,535"}},
+		{"name: app\nlabels: {app.tier: 1}", []string{`document 1: .labels["app.tier"]: got number, want string`}},
+		{"name: app\n---\nname: web\nextra: 1", []string{"document 2: .: additional properties 'extra' not allowed"}},
+	}
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(schema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	saved := validateSchemaFile
+	validateSchemaFile = path
+	defer func() { validateSchemaFile = saved }()
+	for _, tt := range tests {
+		err := validateSchemas([]byte(tt.output))
+		var errs []string
+		if err != nil {
+			lines := strings.Split(err.Error(), "\n  ")
+			if lines[0] != "output does not match its schema:" {
+				t.Errorf("validating %q: %v", tt.output, err)
+				continue
+			}
+			errs = lines[1:]
+		}
+		if !reflect.DeepEqual(errs, tt.errs) {
+			t.Errorf("validating %q = %q, want %q", tt.output, errs, tt.errs)
+		}
+	}
+}
+
+func TestLoadSchemaInvalid(t *testing.T) {
+	tests := []struct {
+		schema string
+		err    string
+	}{
+		{`{"type": "string", "pattern": "^[a-z"}`, "compiling schema"},
+		{`{"patternProperties": {"(": {}}}`, "compiling schema"},
+		{`{"type": "text"}`, "compiling schema"},
+		{`{"$ref": "#/$defs/missing"}`, "compiling schema"},
+		{`{"type": `, "parsing schema"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "schema.json")
+		if err := os.WriteFile(path, []byte(tt.schema), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadSchema(path); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("loadSchema(%s): got error %v, want %s", tt.schema, err, tt.err)
+		}
+	}
+}
+
+func TestInstancePath(t *testing.T) {
+	doc := map[string]interface{}{"spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"a.b": 1}}}}
+	tests := []struct {
+		location []string
+		want     string
+	}{
+		{nil, "."},
+		{[]string{"spec"}, ".spec"},
+		{[]string{"spec", "ports", "0"}, ".spec.ports[0]"},
+		{[]string{"spec", "ports", "0", "a.b"}, `.spec.ports[0]["a.b"]`},
+		{[]string{"missing", "0"}, ".missing.0"},
+	}
+	for _, tt := range tests {
+		if got := instancePath(doc, tt.location); got != tt.want {
+			t.Errorf("instancePath(%q) = %s, want %s", tt.location, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semVersion is a semantic version (see https://semver.org), returned by the
+// semver function. Its fields and methods can be used in templates, e.g.
+// {{ (semver .version).Major }} or {{ (semver .a).LessThan (semver .b) }}.
+type semVersion struct {
+	Major, Minor, Patch uint64
+	Prerelease          string
+	Metadata            string
+}
+
+// versionRe matches versions, with an optional leading 'v', and their partial
+// forms (e.g. 1.2). Components of versions in constraints can be wildcards.
+var (
+	versionRe           = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+	constraintVersionRe = regexp.MustCompile(`^v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+)
+
+// parseSemver parses the version s, the missing minor and patch versions
+// being 0, e.g. v1.2 is 1.2.0.
+func parseSemver(s string) (semVersion, error) {
+	m := versionRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return semVersion{}, fmt.Errorf("invalid semantic version %q", s)
+	}
+	var v semVersion
+	for i, p := range []*uint64{&v.Major, &v.Minor, &v.Patch} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(m[i+1], 10, 64)
+		if err != nil {
+			return semVersion{}, fmt.Errorf("invalid semantic version %q: %w", s, err)
+		}
+		*p = n
+	}
+	v.Prerelease, v.Metadata = m[4], m[5]
+	return v, nil
+}
+
+func (v semVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Metadata != "" {
+		s += "+" + v.Metadata
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v is lower than, equal to (ignoring the build
+// metadata) or greater than o.
+func (v semVersion) Compare(o semVersion) int {
+	for _, c := range [][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c[0] != c[1] {
+			if c[0] < c[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// LessThan reports whether v is lower than o.
+func (v semVersion) LessThan(o semVersion) bool { return v.Compare(o) < 0 }
+
+// GreaterThan reports whether v is greater than o.
+func (v semVersion) GreaterThan(o semVersion) bool { return v.Compare(o) > 0 }
+
+// Equal reports whether v is equal to o, ignoring the build metadata.
+func (v semVersion) Equal(o semVersion) bool { return v.Compare(o) == 0 }
+
+// comparePrerelease compares the pre-release versions a and b: a version
+// without one is greater, and their dot separated identifiers are compared in
+// turn, numerically if both are numbers.
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aErr == nil:
+			// Numeric identifiers are lower than alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] < bs[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// semver parses the semantic version s, e.g. {{ (semver "v1.2.3").Minor }}.
+func semver(s string) (semVersion, error) {
+	return parseSemver(s)
+}
+
+// semverCompare reports whether the version s satisfies constraint, like Helm
+// does, e.g. {{ if semverCompare ">=1.21-0" .kubeVersion }}. Constraints are
+// comparisons (=, !=, >, >=, <, <=, ~ for patch and ^ for minor updates, e.g.
+// ~1.2.3 is >=1.2.3 <1.3.0 and ^1.2.3 is >=1.2.3 <2.0.0) of versions that may
+// be partial or have wildcards (1.2, 1.2.x), separated by spaces or commas
+// (and), '||' (or), or ranges (1.2 - 1.4). Pre-release versions only satisfy
+// constraints that have pre-releases.
+func semverCompare(constraint, s string) (bool, error) {
+	v, err := parseSemver(s)
+	if err != nil {
+		return false, err
+	}
+	groups, err := parseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range groups {
+		if g.matches(v) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// versionComparison is a comparison of a constraint, e.g. >=1.2.
+type versionComparison struct {
+	op string
+	// v is the version compared to, of which n components are set and the
+	// others are wildcards
+	v semVersion
+	n int
+}
+
+// constraintGroup is a list of comparisons that must all be satisfied.
+type constraintGroup []versionComparison
+
+var hyphenRangeRe = regexp.MustCompile(`(\S+)\s+-\s+(\S+)`)
+
+// parseConstraint parses the groups of constraint, separated by '||'.
+func parseConstraint(constraint string) ([]constraintGroup, error) {
+	var groups []constraintGroup
+	for _, g := range strings.Split(constraint, "||") {
+		g = hyphenRangeRe.ReplaceAllString(g, ">=$1 <=$2")
+		var group constraintGroup
+		op := ""
+		for _, tok := range strings.FieldsFunc(g, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' }) {
+			i := strings.IndexFunc(tok, func(r rune) bool { return !strings.ContainsRune("=!<>~^", r) })
+			if i < 0 {
+				// An operator separated from its version
+				op += tok
+				continue
+			}
+			op += tok[:i]
+			c, err := parseComparison(op, tok[i:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", constraint, err)
+			}
+			group = append(group, c)
+			op = ""
+		}
+		if op != "" || len(group) == 0 {
+			return nil, fmt.Errorf("invalid constraint %q", constraint)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func parseComparison(op, s string) (versionComparison, error) {
+	switch op {
+	case "", "=", "==", "!=", ">", ">=", "=>", "<", "<=", "=<", "~", "~>", "^":
+	default:
+		return versionComparison{}, fmt.Errorf("unknown operator %q", op)
+	}
+	m := constraintVersionRe.FindStringSubmatch(s)
+	if m == nil {
+		return versionComparison{}, fmt.Errorf("invalid version %q", s)
+	}
+	c := versionComparison{op: op}
+	for i, p := range []*uint64{&c.v.Major, &c.v.Minor, &c.v.Patch} {
+		if m[i+1] == "" || strings.ContainsAny(m[i+1], "xX*") {
+			break
+		}
+		n, err := strconv.ParseUint(m[i+1], 10, 64)
+		if err != nil {
+			return versionComparison{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		*p = n
+		c.n++
+	}
+	c.v.Prerelease = m[4]
+	return c, nil
+}
+
+// bump returns v with its nth component (1 for the major version) incremented
+// and the following ones reset.
+func bump(v semVersion, n int) semVersion {
+	switch n {
+	case 1:
+		return semVersion{Major: v.Major + 1}
+	case 2:
+		return semVersion{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return semVersion{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+// within reports whether v is in the range [lower, upper).
+func within(v, lower, upper semVersion) bool {
+	return v.Compare(lower) >= 0 && v.LessThan(upper)
+}
+
+func (c versionComparison) matches(v semVersion) bool {
+	lower := c.v
+	switch c.op {
+	case "", "=", "==":
+		if c.n == 3 {
+			return v.Equal(c.v)
+		}
+		return c.n == 0 || within(v, lower, bump(c.v, c.n))
+	case "!=":
+		if c.n == 3 {
+			return !v.Equal(c.v)
+		}
+		return c.n != 0 && !within(v, lower, bump(c.v, c.n))
+	case ">":
+		if c.n == 3 {
+			return v.GreaterThan(c.v)
+		}
+		return c.n != 0 && v.Compare(bump(c.v, c.n)) >= 0
+	case ">=", "=>":
+		return v.Compare(lower) >= 0
+	case "<":
+		return v.LessThan(lower)
+	case "<=", "=<":
+		if c.n == 3 {
+			return v.Compare(c.v) <= 0
+		}
+		return c.n == 0 || v.LessThan(bump(c.v, c.n))
+	case "~", "~>":
+		switch c.n {
+		case 0:
+			return true
+		case 1:
+			return within(v, lower, bump(c.v, 1))
+		}
+		return within(v, lower, bump(c.v, 2))
+	case "^":
+		switch {
+		case c.n == 0:
+			return true
+		case c.v.Major > 0 || c.n == 1:
+			return within(v, lower, bump(c.v, 1))
+		case c.v.Minor > 0 || c.n == 2:
+			return within(v, lower, bump(c.v, 2))
+		}
+		return within(v, lower, bump(c.v, 3))
+	}
+	return false
+}
+
+func (g constraintGroup) matches(v semVersion) bool {
+	if v.Prerelease != "" {
+		prerelease := false
+		for _, c := range g {
+			prerelease = prerelease || c.v.Prerelease != ""
+		}
+		if !prerelease {
+			return false
+		}
+	}
+	for _, c := range g {
+		if !c.matches(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// shellRef is a $VAR or ${VAR} reference in a shell format template.
+type shellRef struct {
+	name string
+	// op is "", "-" or ":-" for ${VAR}, ${VAR-default} and ${VAR:-default}.
+	op  string
+	def string
+}
+
+// shellTemplate is a parsed shell format (envsubst style) template, made of
+// alternating literal text and variable references.
+type shellTemplate struct {
+	literals []string
+	refs     []shellRef
+}
+
+func isShellNameChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
+
+// parseShellTemplate parses text containing $VAR, ${VAR}, ${VAR-default} and
+// ${VAR:-default} references. A '$' not followed by a valid name is kept as is.
+func parseShellTemplate(text string) (*shellTemplate, error) {
+	t := &shellTemplate{}
+	var lit strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '$' || i+1 == len(text) {
+			lit.WriteByte(text[i])
+			continue
+		}
+		var ref shellRef
+		if text[i+1] == '{' {
+			end := strings.IndexByte(text[i+2:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated variable reference at offset %d", i)
+			}
+			body := text[i+2 : i+2+end]
+			n := 0
+			for n < len(body) && isShellNameChar(body[n], n == 0) {
+				n++
+			}
+			ref.name = body[:n]
+			switch rest := body[n:]; {
+			case ref.name == "":
+				return nil, fmt.Errorf("invalid variable reference %q at offset %d", "${"+body+"}", i)
+			case rest == "":
+			case strings.HasPrefix(rest, ":-"):
+				ref.op, ref.def = ":-", rest[2:]
+			case strings.HasPrefix(rest, "-"):
+				ref.op, ref.def = "-", rest[1:]
+			default:
+				return nil, fmt.Errorf("invalid variable reference %q at offset %d", "${"+body+"}", i)
+			}
+			i += 2 + end
+		} else {
+			n := i + 1
+			for n < len(text) && isShellNameChar(text[n], n == i+1) {
+				n++
+			}
+			if n == i+1 {
+				lit.WriteByte(text[i])
+				continue
+			}
+			ref.name = text[i+1 : n]
+			i = n - 1
+		}
+		t.literals = append(t.literals, lit.String())
+		t.refs = append(t.refs, ref)
+		lit.Reset()
+	}
+	t.literals = append(t.literals, lit.String())
+	return t, nil
+}
+
+// Execute writes the template to w, replacing each reference with the value of
+// the matching top-level key in data. Missing keys are replaced with an empty
+// string, unless a default is given or the --missing-key policy is error or
+// warn.
+func (t *shellTemplate) Execute(w io.Writer, data interface{}) error {
+	m, _ := data.(map[string]interface{})
+	var sb strings.Builder
+	for i, ref := range t.refs {
+		sb.WriteString(t.literals[i])
+		v, ok := m[ref.name]
+		s := ""
+		if ok && v != nil {
+			s = fmt.Sprint(v)
+		}
+		switch {
+		case ref.op == "-" && !ok, ref.op == ":-" && s == "":
+			s = ref.def
+		case !ok && missingKey == "error":
+			return fmt.Errorf("map has no entry for key %q", ref.name)
+		case !ok && missingKey == "warn":
+			log.Printf("Warning: %s is not set\n", ref.name)
+		}
+		if traceFlag {
+			log.Printf("Trace: $%s = %s\n", ref.name, traceValue(ref.name, s))
+		}
+		sb.WriteString(s)
+	}
+	sb.WriteString(t.literals[len(t.literals)-1])
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/getsops/sops/v3/decrypt"
+)
+
+// isSOPSEncrypted reports whether data (a decoded JSON, YAML or dotenv file)
+// has the metadata added by SOPS to encrypted files.
+func isSOPSEncrypted(data interface{}) bool {
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, ok := m["sops_mac"]; ok {
+		return true
+	}
+	meta, ok := m["sops"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = meta["mac"]
+	return ok
+}
+
+// sopsDecrypt decrypts the SOPS-encrypted contents b of src with the SOPS
+// library, using the decryption keys SOPS finds (age and PGP keys, cloud KMS
+// credentials...). The plaintext is kept in memory and never written to disk.
+func sopsDecrypt(src dataSource, b []byte) ([]byte, error) {
+	if src.format != "json" && src.format != "yaml" && src.format != "dotenv" {
+		return nil, fmt.Errorf("%s: SOPS decryption is not supported for %s data sources", src.path, src.format)
+	}
+	plain, err := decrypt.Data(b, src.format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: decrypting with sops: %w", src.path, err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// splitWords splits the identifier s into its words, separated by any other
+// character than letters and digits (e.g. '_', '-' or spaces) or by a change
+// of case: "apiKey", "api_key", "API-Key" and "APIKey" are all "api" and "key".
+// Digits belong to the word they follow.
+func splitWords(s string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(s)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 {
+			prev := word[len(word)-1]
+			// A new word starts at an uppercase letter following a lowercase
+			// letter or digit (apiKey), or ending an acronym (APIKey)
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || next {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// camelCase converts s to lower camel case, e.g. "api_key" to "apiKey".
+func camelCase(s string) string {
+	var b strings.Builder
+	for i, w := range splitWords(s) {
+		w = strings.ToLower(w)
+		if i > 0 {
+			w = upperFirst(w)
+		}
+		b.WriteString(w)
+	}
+	return b.String()
+}
+
+// snakeCase converts s to snake case, e.g. "apiKey" to "api_key".
+func snakeCase(s string) string {
+	return strings.ToLower(strings.Join(splitWords(s), "_"))
+}
+
+// kebabCase converts s to kebab case, e.g. "apiKey" to "api-key".
+func kebabCase(s string) string {
+	return strings.ToLower(strings.Join(splitWords(s), "-"))
+}
+
+// title uppercases the first letter of every word of s, e.g. "hello world" to
+// "Hello World".
+func title(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '-' || runes[i-1] == '_' {
+			runes[i] = unicode.ToTitle(r)
+		}
+	}
+	return string(runes)
+}
+
+// upperFirst uppercases the first letter of s.
+func upperFirst(s string) string {
+	for i, r := range s {
+		return string(unicode.ToTitle(r)) + s[i+len(string(r)):]
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+// errorContextLines is the number of template lines shown before and after
+// the line of an error.
+const errorContextLines = 2
+
+// templateErrorRe matches the errors of go templates, e.g.
+// template: name:12:5: executing "name" at <.key>: map has no entry for key "key"
+// (execution errors) or template: name:12: unexpected "}" in operand (parse
+// errors).
+var templateErrorRe = regexp.MustCompile(`(?s)^template: (.+?):(\d+):(?:(\d+):)? (?:executing "[^"]*" at <(.*?)>: )?(.*)$`)
+
+// internalCallRe matches the prefix of the errors of the functions templates
+// are rewritten to call (e.g. _field with --ignore-case), which are hidden.
+var internalCallRe = regexp.MustCompile(`^error calling _\w+: `)
+
+// templateTexts holds the texts of the parsed templates by name, to show the
+// source of their errors.
+var templateTexts sync.Map
+
+type templateText struct {
+	// parsed is text with its raw blocks expanded
+	text, parsed string
+	lineOffset   int
+}
+
+// registerTemplateText records the text of the template named name, parsed
+// as parsed and preceded by lineOffset lines in its file.
+func registerTemplateText(name, text, parsed string, lineOffset int) {
+	templateTexts.Store(name, templateText{text: text, parsed: parsed, lineOffset: lineOffset})
+}
+
+// templateError is an error of a go template, described with its location,
+// the data path involved and the surrounding template source.
+type templateError struct {
+	msg string
+	err error
+}
+
+func (e *templateError) Error() string {
+	return e.msg
+}
+
+func (e *templateError) Unwrap() error {
+	return e.err
+}
+
+// describeTemplateError rewrites err, an error parsing or executing tpl (nil
+// for parse errors), as a templateError, e.g.
+//
+//	template.txt:3:9: map has no entry for key "name" (data path .services[].name)
+//	  2 | {{ range .services }}
+//	> 3 | name: {{ .name }}
+//	    |          ^
+//	  4 | {{ end }}
+//
+// Other errors are returned as is.
+func describeTemplateError(err error, tpl executor) error {
+	m := templateErrorRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	name, expr, msg := m[1], m[4], internalCallRe.ReplaceAllString(m[5], "")
+	line, _ := strconv.Atoi(m[2])
+	col := -1
+	if m[3] != "" {
+		col, _ = strconv.Atoi(m[3])
+	}
+	var src templateText
+	if v, ok := templateTexts.Load(name); ok {
+		src = v.(templateText)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:%d", name, line+src.lineOffset)
+	if col >= 0 {
+		fmt.Fprintf(&b, ":%d", col+1)
+	}
+	b.WriteString(": " + msg)
+	if expr != "" {
+		if path := errorDataPath(tpl, name, src.parsed, line, col); path != "" {
+			fmt.Fprintf(&b, " (data path %s)", path)
+		} else {
+			fmt.Fprintf(&b, " (at %s)", expr)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(src.text, "\n"), "\n")
+	if src.text == "" || line < 1 || line > len(lines) {
+		return &templateError{msg: b.String(), err: err}
+	}
+	first, last := line-errorContextLines, line+errorContextLines
+	if first < 1 {
+		first = 1
+	}
+	if last > len(lines) {
+		last = len(lines)
+	}
+	width := len(strconv.Itoa(last + src.lineOffset))
+	for i := first; i <= last; i++ {
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		text := strings.TrimRight(lines[i-1], "\r")
+		fmt.Fprintf(&b, "\n%s %*d | %s", marker, width, i+src.lineOffset, text)
+		if i == line && col >= 0 && col <= len(text) {
+			// Tabs are kept so the caret lines up with the source
+			pad := strings.Map(func(r rune) rune {
+				if r == '\t' {
+					return r
+				}
+				return ' '
+			}, text[:col])
+			fmt.Fprintf(&b, "\n  %*s | %s^", width, "", pad)
+		}
+	}
+	return &templateError{msg: b.String(), err: err}
+}
+
+// errorDataPath returns the data path accessed at line and col of the text of
+// the template named name, resolved statically from the parse tree of tpl
+// (relative to the data the template is rendered against), or "" if unknown.
+func errorDataPath(tpl executor, name, text string, line, col int) string {
+	if t, ok := tpl.(frontMatterTemplate); ok {
+		tpl = t.executor
+	}
+	t, ok := tpl.(*template.Template)
+	if !ok || t.Tree == nil || col < 0 {
+		return ""
+	}
+	pos := col
+	for i, l := range strings.SplitAfter(text, "\n") {
+		if i == line-1 {
+			break
+		}
+		pos += len(l)
+	}
+	w := &keyWalker{
+		tpl:       t,
+		keys:      make(map[string]bool),
+		visiting:  make(map[string]bool),
+		positions: make(map[string]string),
+		parseName: t.Tree.ParseName,
+	}
+	w.walk(t.Tree.Root, ".", map[string]string{"$": "."})
+	return w.positions[nodePosition(name, parse.Pos(pos))]
+}
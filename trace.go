@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"text/template"
+	"text/template/parse"
+)
+
+// traceFuncName is the function appended to the pipelines of templates to log
+// their values with --trace.
+const traceFuncName = "_trace"
+
+// maskedValue replaces the values of secrets in logs.
+const maskedValue = "******"
+
+// secretKeyPattern matches the keys and expressions whose values are masked in
+// the --trace output, as well as those matching --mask-keys.
+var secretKeyPattern = regexp.MustCompile(defaultSecretPattern)
+
+// traceFuncs returns the functions needed by templates rewritten with
+// traceTemplate.
+func traceFuncs() template.FuncMap {
+	return template.FuncMap{
+		traceFuncName: func(location, expr string, v interface{}) interface{} {
+			log.Printf("Trace: %s: {{ %s }} = %s\n", location, expr, traceValue(expr, v))
+			return v
+		},
+	}
+}
+
+// traceValue formats the value v of the expression expr for the --trace
+// output, masking the values of secrets: the whole value if expr references a
+// secret key, else those of the secret keys it contains (e.g. for {{ .db }}).
+func traceValue(expr string, v interface{}) string {
+	switch {
+	case v == nil:
+		return "<no value>"
+	case isSecretKey(expr), isMaskedExpr(expr):
+		return maskedValue
+	}
+	return maskSecrets(toJSONString(maskSecretValues(v)))
+}
+
+// isSecretKey reports whether the key name (or expression) looks like it
+// holds a secret, or matches --mask-keys.
+func isSecretKey(name string) bool {
+	return secretKeyPattern.MatchString(name) || isMaskedKey(name)
+}
+
+// maskSecretValues returns a copy of v with the values of the map entries
+// whose keys hold secrets masked, at any depth.
+func maskSecretValues(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if isSecretKey(k) {
+				c[k] = maskedValue
+			} else {
+				c[k] = maskSecretValues(e)
+			}
+		}
+		if keys, ok := recordedKeys(v); ok {
+			setKeyOrder(c, append([]string(nil), keys...))
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = maskSecretValues(e)
+		}
+		return c
+	}
+	return v
+}
+
+// traceTemplate rewrites every pipeline evaluated by tpl (printed actions,
+// variable assignments, and the conditions of if, range and with) so that
+// their location, expression and value are logged when rendering.
+func traceTemplate(tpl *template.Template) {
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil && t.Tree.Root != nil {
+			traceNode(t.Tree, t.Tree.Root)
+		}
+	}
+}
+
+func traceNode(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			traceNode(tree, c)
+		}
+	case *parse.ActionNode:
+		tracePipe(tree, n, n.Pipe)
+	case *parse.IfNode:
+		tracePipe(tree, n, n.Pipe)
+		traceNode(tree, n.List)
+		traceNode(tree, n.ElseList)
+	case *parse.RangeNode:
+		tracePipe(tree, n, n.Pipe)
+		traceNode(tree, n.List)
+		traceNode(tree, n.ElseList)
+	case *parse.WithNode:
+		tracePipe(tree, n, n.Pipe)
+		traceNode(tree, n.List)
+		traceNode(tree, n.ElseList)
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			tracePipe(tree, n, n.Pipe)
+		}
+	}
+}
+
+func tracePipe(tree *parse.Tree, node parse.Node, pipe *parse.PipeNode) {
+	location, _ := tree.ErrorContext(node)
+	expr := pipe.String()
+	pos := pipe.Position()
+	pipe.Cmds = append(pipe.Cmds, &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Pos:      pos,
+		Args: []parse.Node{
+			parse.NewIdentifier(traceFuncName).SetTree(tree).SetPos(pos),
+			&parse.StringNode{NodeType: parse.NodeString, Pos: pos, Quoted: strconv.Quote(location), Text: location},
+			&parse.StringNode{NodeType: parse.NodeString, Pos: pos, Quoted: strconv.Quote(expr), Text: expr},
+		},
+	})
+}
+
+// toJSONString encodes v as compact JSON for error messages.
+func toJSONString(v interface{}) string {
+	s, err := toJSON(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTraceValue(t *testing.T) {
+	tests := []struct {
+		expr string
+		v    interface{}
+		want string
+	}{
+		{".name", "app", `"app"`},
+		{".missing", nil, "<no value>"},
+		{".replicas", 3, "3"},
+		{".db.password", "hunter22", maskedValue},
+		{`index .db "apiToken"`, "abc", maskedValue},
+		{".db", map[string]interface{}{"host": "h", "password": "p"}, `{"host":"h","password":"******"}`},
+		{".clusters", []interface{}{map[string]interface{}{"name": "a", "auth": map[string]interface{}{"token": "t"}}},
+			`[{"auth":{"token":"******"},"name":"a"}]`},
+	}
+	for _, tt := range tests {
+		if got := traceValue(tt.expr, tt.v); got != tt.want {
+			t.Errorf("traceValue(%q, %v) = %s, want %s", tt.expr, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	setTestOptions(t)
+	traceFlag = true
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	data := map[string]interface{}{"name": "app", "ports": []interface{}{80, 443}, "token": "s3cr3t"}
+	text := "{{ $n := .name }}{{ if .ports }}{{ range .ports }}{{ . }},{{ end }}{{ end }}{{ $n | upper }}{{ .token }}"
+	got, err := renderText(text, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "80,443,APPs3cr3t"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, want := range []string{
+		`Trace: test:1:3: {{ $n := .name }} = "app"`,
+		`{{ .ports }} = [80,443]`,
+		`{{ . }} = 443`,
+		`{{ $n | upper }} = "APP"`,
+		`{{ .token }} = ******`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("trace %q doesn't contain %q", logs.String(), want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validateOutput checks that the rendered output b is well-formed in the
+// format selected with --validate, and that it matches its schema with
+// --validate-schema or --validate-kubernetes.
+func validateOutput(b []byte) error {
+	var err error
+	switch validateFormat {
+	case "json":
+		err = validateJSON(b)
+	case "yaml":
+		err = validateYAML(b)
+	}
+	if err != nil || validateSchemaFile == "" && !validateKubernetes {
+		return err
+	}
+	return validateSchemas(b)
+}
+
+func validateJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	var v interface{}
+	err := dec.Decode(&v)
+	if err == nil {
+		// Only a single JSON value is allowed.
+		if _, err = dec.Token(); err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			err = errors.New("unexpected data after top-level value")
+		}
+	}
+	offset := dec.InputOffset()
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	}
+	line, col := lineColumn(b, offset)
+	return fmt.Errorf("output is not valid JSON: line %d, column %d: %v", line, col, err)
+}
+
+func validateYAML(b []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("output is not valid YAML: %v", err)
+		}
+	}
+}
+
+// lineColumn converts a byte offset in b into 1-based line and column numbers.
+func lineColumn(b []byte, offset int64) (int, int) {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	before := b[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long to wait for further changes before rendering
+// again, so that editors saving several files at once trigger a single render.
+const watchDebounce = 200 * time.Millisecond
+
+// watch blocks, rendering again whenever the input template(s) or local data
+// sources change. If a command is given, it is started once the outputs are
+// rendered (immediately if rendered is true) and reloaded after every render.
+// datasubst then forwards its signals to the command and exits with it.
+func watch(rendered bool) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	var child *process
+	sigs := make(chan os.Signal, 1)
+	if len(command) > 0 {
+		signal.Notify(sigs, forwardedSignals...)
+		defer signal.Stop(sigs)
+		if rendered {
+			if child, err = startProcess(command); err != nil {
+				return fmt.Errorf("running %s: %w", command[0], err)
+			}
+		}
+	}
+
+	// Files are watched through their parent directory so that editors that
+	// replace files on save (rather than writing them in place) are handled.
+	files := make(map[string]bool)
+	var dirs []string
+	addFile := func(path string) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		files[abs] = true
+		return w.Add(filepath.Dir(abs))
+	}
+	addDir := func(path string) error {
+		return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return err
+			}
+			abs, err := filepath.Abs(p)
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, abs)
+			return w.Add(abs)
+		})
+	}
+
+	for _, in := range inputs {
+		if info, statErr := os.Stat(in); statErr == nil && info.IsDir() {
+			err = addDir(in)
+		} else if !isURL(in) {
+			err = addFile(in)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, pattern := range templateGlobs {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			if err := addFile(m); err != nil {
+				return err
+			}
+		}
+	}
+	for _, src := range dataSources {
+		if src.isFile() {
+			if err := addFile(src.path); err != nil {
+				return err
+			}
+		}
+	}
+
+	ignored := func(path string) bool {
+		if isTempFile(path) {
+			return true
+		}
+		if outputDir != "" {
+			if abs, err := filepath.Abs(outputDir); err == nil && isSubPath(abs, path) {
+				return true
+			}
+		}
+		if outputFile != "" {
+			if abs, err := filepath.Abs(outputFile); err == nil && abs == path {
+				return true
+			}
+		}
+		return false
+	}
+	relevant := func(path string) bool {
+		if files[path] {
+			return true
+		}
+		for _, d := range dirs {
+			if isSubPath(d, path) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			path, err := filepath.Abs(event.Name)
+			if err != nil || ignored(path) || !relevant(path) {
+				continue
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(path); err == nil && info.IsDir() {
+					if err := addDir(path); err != nil {
+						log.Printf("Error watching %s: %v\n", path, err)
+					}
+				}
+			}
+			timer = time.After(watchDebounce)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Error watching files: %v\n", err)
+		case <-timer:
+			timer = nil
+			if err := render(); err != nil {
+				log.Printf("Error %v\n", err)
+				continue
+			}
+			switch {
+			case len(command) == 0:
+			case child == nil:
+				if child, err = startProcess(command); err != nil {
+					return fmt.Errorf("running %s: %w", command[0], err)
+				}
+			default:
+				if err := child.reload(); err != nil {
+					return fmt.Errorf("reloading %s: %w", command[0], err)
+				}
+			}
+		case sig := <-sigs:
+			if child == nil {
+				os.Exit(1)
+			}
+			child.signal(sig)
+		case err := <-child.exited():
+			code, err := commandStatus(err)
+			if err != nil {
+				return err
+			}
+			os.Exit(code)
+		}
+	}
+}
+
+// isSubPath reports whether path is dir or is inside dir.
+func isSubPath(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// blockActionRe matches the content of the actions that output nothing:
+// control structures, comments and variable declarations and assignments.
+var blockActionRe = regexp.MustCompile(`^(?:(?:if|else|end|range|with|define|block|break|continue)\b|/\*|\$\w*(?:\s*,\s*\$\w*)?\s*:?=)`)
+
+// trimBlockWhitespace applies --trim-blocks and --lstrip-blocks to text,
+// written with the delimiters left and right, like Jinja does: the first
+// newline after a block action (one that outputs nothing, e.g. {{ if }},
+// {{ end }} or {{/* comment */}}) is removed, and the spaces and tabs from the
+// start of a line to a block action are stripped. Removed newlines are moved
+// within the actions, where they are ignored, to preserve the lines.
+func trimBlockWhitespace(text, left, right string) string {
+	if !trimBlocks && !lstripBlocks {
+		return text
+	}
+	if left == "" {
+		left, right = "{{", "}}"
+	}
+	var b strings.Builder
+	lineStart := true
+	for {
+		i := strings.Index(text, left)
+		if i < 0 {
+			b.WriteString(text)
+			return b.String()
+		}
+		n := actionLength(text[i+len(left):], right)
+		if n < 0 {
+			// Left to the parser to report
+			b.WriteString(text)
+			return b.String()
+		}
+		before := text[:i]
+		inner := text[i+len(left) : i+len(left)+n]
+		text = text[i+len(left)+n+len(right):]
+		content := strings.TrimLeft(strings.TrimPrefix(inner, "-"), " \t\r\n")
+		if !blockActionRe.MatchString(content) {
+			b.WriteString(before)
+			b.WriteString(left + inner + right)
+			lineStart = false
+			continue
+		}
+
+		if lstripBlocks {
+			start := strings.LastIndexByte(before, '\n') + 1
+			if start > 0 || lineStart {
+				if strings.Trim(before[start:], " \t") == "" {
+					before = before[:start]
+				}
+			}
+		}
+		lineStart = false
+		if trimBlocks && !hasRightTrim(inner) {
+			newline := ""
+			if strings.HasPrefix(text, "\n") {
+				newline = "\n"
+			} else if strings.HasPrefix(text, "\r\n") {
+				newline = "\r\n"
+			}
+			if newline != "" {
+				text = text[len(newline):]
+				lineStart = true
+				if strings.HasSuffix(inner, "*/") {
+					// Comments must end right before the delimiter
+					inner = inner[:len(inner)-2] + "\n*/"
+				} else {
+					inner += "\n"
+				}
+			}
+		}
+		b.WriteString(before)
+		b.WriteString(left + inner + right)
+	}
+}
+
+// actionLength returns the length of the content of the action at the start
+// of s (after its left delimiter), up to its right delimiter, skipping the
+// strings and comments it contains, or -1 if it isn't closed.
+func actionLength(s, right string) int {
+	if c := strings.TrimLeft(strings.TrimPrefix(s, "-"), " \t\r\n"); strings.HasPrefix(c, "/*") {
+		end := strings.Index(c, "*/")
+		if end < 0 {
+			return -1
+		}
+		offset := len(s) - len(c) + end + 2
+		n := strings.Index(s[offset:], right)
+		if n < 0 {
+			return -1
+		}
+		return offset + n
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], right) {
+			return i
+		}
+		switch q := s[i]; q {
+		case '"', '\'', '`':
+			for i++; i < len(s) && s[i] != q; i++ {
+				if s[i] == '\\' && q != '`' {
+					i++
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// hasRightTrim reports whether the content of an action ends with a trim
+// marker (e.g. {{ end -}}).
+func hasRightTrim(inner string) bool {
+	return len(inner) >= 2 && inner[len(inner)-1] == '-' && strings.ContainsAny(inner[len(inner)-2:len(inner)-1], " \t\r\n")
+}
+
+// chompNewline drops a single trailing newline from b with --chomp.
+func chompNewline(b []byte) []byte {
+	if !chomp {
+		return b
+	}
+	if n := len(b); n > 0 && b[n-1] == '\n' {
+		b = b[:n-1]
+		if n := len(b); n > 0 && b[n-1] == '\r' {
+			b = b[:n-1]
+		}
+	}
+	return b
+}